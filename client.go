@@ -2,11 +2,20 @@ package websocket
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/face"
+	"github.com/andyzhou/websocket/gvar"
 	"golang.org/x/net/websocket"
 )
 
@@ -23,18 +32,68 @@ const (
 	BinaryMessage
 )
 
+const (
+	closeCodeMessageTooBig = 1009
+)
+
 type writeMessage struct {
 	messageType MessageType
 	data        []byte
 }
 
+//codec for sending close frame
+var closeCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		data, _ := v.([]byte)
+		return data, websocket.CloseFrame, nil
+	},
+}
+
+//raw codec, keep the frame type of received message
+var rawCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		msg, ok := v.(writeMessage)
+		if !ok {
+			return nil, websocket.UnknownFrame, websocket.ErrNotSupported
+		}
+		if msg.messageType == BinaryMessage {
+			return msg.data, websocket.BinaryFrame, nil
+		}
+		return msg.data, websocket.TextFrame, nil
+	},
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		msg, ok := v.(*writeMessage)
+		if !ok {
+			return websocket.ErrNotSupported
+		}
+		msg.messageType = TextMessage
+		if payloadType == websocket.BinaryFrame {
+			msg.messageType = BinaryMessage
+		}
+		msg.data = data
+		return nil
+	},
+}
+
 //client option
 type ClientOption struct {
 	Reconnect            bool
 	ReconnectMax         int
 	ReconnectCount       int
 	ReconnectBaseSeconds int
-	HeartbeatSeconds     int
+	HeartbeatSeconds     int //zero means no heartbeat
+	MaxMessageSize       int //max bytes of one received message, zero means 32MB
+	MaxFramesPerMessage  int //max frames of one received fragmented message, zero means no limit
+	EnableRpc            bool //request and response rpc with server
+	EnableEvent          bool //named event with server
+	EnableReliable       bool //auto ack reliable message and drop duplicated one
+	EnableResume         bool //keep session token and resume session when reconnect
+	EnableSeq            bool //unwrap group cast with seq, drop duplicated one and notify gap
+
+	//handshake options
+	Header    http.Header //extra header fields of handshake
+	Protocols []string    //sub protocols
+	TlsConfig *tls.Config //tls config for `wss`
 }
 
 //client face
@@ -56,10 +115,39 @@ type Client struct {
 	reconnectBase   time.Duration
 	heartbeatPeriod time.Duration
 
+	header    http.Header
+	protocols []string
+	tlsConfig *tls.Config
+
+	maxMessageSize      int
+	maxFramesPerMessage int
+	tap                 *face.CloseTap //count size and frames of fragmented message, nil if no limit
+	readFrames          int64          //read data frames of current connect
+
+	//rpc
+	rpcRouter  *face.RpcRouter
+	rpcPending *face.RpcPending
+
+	//named event
+	eventRouter *face.EventRouter
+	eventAcks   *face.EventAcks
+
+	//reliable delivery
+	reliableWindow *face.ReliableWindow
+
+	//session resumption
+	enableResume bool
+	sessionToken string
+
+	//group cast seq, group id -> last seq
+	seqMap map[string]int64
+
 	closeOnce sync.Once
 
 	//cb functions
 	OnConnect func()
+	OnResumed func() //session resumed after reconnect
+	OnGap     func(group string, lastSeq, seq int64) //seq gap found, use `RequestHistory` to replay
 	OnMessage func(messageType MessageType, data []byte)
 	OnError   func(err error)
 	OnClose   func()
@@ -85,24 +173,68 @@ func NewClient(url, origin string, options ...ClientOption) *Client {
 		client.reconnectMax = option.ReconnectMax
 		client.reconnectBase = time.Duration(option.ReconnectBaseSeconds) * time.Second
 		client.heartbeatPeriod = time.Duration(option.HeartbeatSeconds) * time.Second
+		client.header = option.Header
+		client.protocols = option.Protocols
+		client.tlsConfig = option.TlsConfig
+		client.maxMessageSize = option.MaxMessageSize
+		client.maxFramesPerMessage = option.MaxFramesPerMessage
+		if option.EnableRpc {
+			client.rpcRouter = face.NewRpcRouter()
+			client.rpcPending = face.NewRpcPending()
+		}
+		if option.EnableEvent {
+			client.eventRouter = face.NewEventRouter()
+			client.eventAcks = face.NewEventAcks()
+		}
+		if option.EnableReliable {
+			client.reliableWindow = face.NewReliableWindow()
+		}
+		client.enableResume = option.EnableResume
+		if option.EnableSeq {
+			client.seqMap = map[string]int64{}
+		}
 	}
 	return client
 }
 
 //connect server
 func (c *Client) Connect() error {
-	config, err := websocket.NewConfig(c.url, c.origin)
+	config, err := websocket.NewConfig(c.genUrl(), c.origin)
 	if err != nil {
 		return err
 	}
-
-	conn, subErr := websocket.DialConfig(config)
+	if c.header != nil {
+		config.Header = c.header
+	}
+	config.Protocol = c.protocols
+	config.TlsConfig = c.tlsConfig
+
+	var (
+		conn   *websocket.Conn
+		tap    *face.CloseTap
+		subErr error
+	)
+	if c.maxMessageSize > 0 || c.maxFramesPerMessage > 0 {
+		conn, tap, subErr = c.dialWithTap(config)
+	}else{
+		conn, subErr = websocket.DialConfig(config)
+	}
 	if subErr != nil {
 		return subErr
 	}
 
+	if c.maxMessageSize > 0 {
+		conn.MaxPayloadBytes = c.maxMessageSize
+	}
+
 	c.connMu.Lock()
 	c.conn = conn
+	c.tap = tap
+	c.readFrames = 0
+	if c.seqMap != nil && c.sessionToken == "" {
+		//new session, group seq may restart
+		c.resetSeq()
+	}
 	c.connMu.Unlock()
 
 	c.reconnectCount = 0
@@ -125,15 +257,51 @@ func (c *Client) readLoop() {
 		case <-c.ctx.Done():
 			return
 		default:
-			var msg []byte
-			err := websocket.Message.Receive(c.conn, &msg)
+			var msg writeMessage
+			err := rawCodec.Receive(c.conn, &msg)
 			if err != nil {
+				if c.ctx.Err() != nil {
+					//closed by self
+					return
+				}
+				if errors.Is(err, websocket.ErrFrameTooLarge) {
+					c.rejectTooBig()
+				}
 				c.handleError(err)
 				return
 			}
+			c.readFrames++
+			if c.tap != nil && c.tap.IsTooBig(c.readFrames) {
+				//fragmented message over max size or frames
+				c.rejectTooBig()
+				c.handleError(websocket.ErrFrameTooLarge)
+				return
+			}
 
+			if c.handleRpc(msg.data) || c.handleEvent(msg.data) || c.handleSession(msg.data) {
+				continue
+			}
+			if c.reliableWindow != nil {
+				if reliableMsg := face.ParseReliableMsg(msg.data); reliableMsg != nil {
+					//pass data of reliable message to read cb
+					if !c.handleReliable(reliableMsg) {
+						continue
+					}
+					msg.data = reliableMsg.Data
+				}
+			}
+			if c.seqMap != nil {
+				c.resetSeqOfJoined(msg.data)
+				if seqMsg := parseSeqMsg(msg.data); seqMsg != nil {
+					//pass data of group cast to read cb
+					if !c.handleSeq(seqMsg) {
+						continue
+					}
+					msg.data = seqMsg.Data
+				}
+			}
 			if c.OnMessage != nil {
-				c.OnMessage(TextMessage, msg)
+				c.OnMessage(msg.messageType, msg.data)
 			}
 		}
 	}
@@ -154,12 +322,7 @@ func (c *Client) writeLoop() {
 				continue
 			}
 
-			var err error
-			if msg.messageType == TextMessage {
-				err = websocket.Message.Send(conn, string(msg.data))
-			} else {
-				err = websocket.Message.Send(conn, msg.data)
-			}
+			err := rawCodec.Send(conn, msg)
 
 			if err != nil {
 				c.handleError(err)
@@ -171,6 +334,9 @@ func (c *Client) writeLoop() {
 
 //heart beat
 func (c *Client) heartbeatLoop() {
+	if c.heartbeatPeriod <= 0 {
+		return
+	}
 	ticker := time.NewTicker(c.heartbeatPeriod)
 	defer ticker.Stop()
 
@@ -184,6 +350,15 @@ func (c *Client) heartbeatLoop() {
 	}
 }
 
+//get sub protocol accepted by server
+func (c *Client) GetProtocol() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	if c.conn == nil || len(c.conn.Config().Protocol) <= 0 {
+		return ""
+	}
+	return c.conn.Config().Protocol[0]
+}
 
 //send text data
 func (c *Client) SendText(data string) error {
@@ -210,6 +385,314 @@ func (c *Client) SendBinary(data []byte) error {
 	}
 }
 
+//register rpc method handler for server calls
+func (c *Client) RegisterRpc(method string, handler gvar.RpcHandler) error {
+	if c.rpcRouter == nil {
+		return face.ErrRpcNotEnabled
+	}
+	return c.rpcRouter.Register(method, handler)
+}
+
+//call server method and wait response
+func (c *Client) Call(ctx context.Context, method string, payload interface{}) (json.RawMessage, error) {
+	if c.rpcPending == nil {
+		return nil, face.ErrRpcNotEnabled
+	}
+	return c.rpcPending.Call(ctx, method, payload, c.writeRpc)
+}
+
+//check and handle rpc message
+//return true if it's rpc message
+func (c *Client) handleRpc(data []byte) bool {
+	if c.rpcPending == nil {
+		return false
+	}
+	msg := face.ParseRpcMsg(data)
+	if msg == nil {
+		return false
+	}
+	if msg.Rpc == define.RpcOfResponse {
+		c.rpcPending.Resolve(msg)
+		return true
+	}
+
+	//serve request in new goroutine, handler may call server too
+	go func() {
+		resp := c.rpcRouter.Serve(c, msg)
+		if err := c.writeRpc(resp); err != nil {
+			log.Printf("client write rpc response failed, err:%v\n", err)
+		}
+	}()
+	return true
+}
+
+//write rpc message as json text
+func (c *Client) writeRpc(msg *gvar.RpcMsg) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.SendText(string(data))
+}
+
+//register named event handler
+func (c *Client) On(event string, handler gvar.EventHandler) error {
+	if c.eventRouter == nil {
+		return face.ErrEventNotEnabled
+	}
+	return c.eventRouter.On(event, handler)
+}
+
+//emit named event to server
+//ack callback is optional, need event enabled
+func (c *Client) Emit(event string, data interface{}, acks ...func(data json.RawMessage)) error {
+	msg, err := face.GenEventMsg(event, data, c.eventAcks, acks...)
+	if err != nil {
+		return err
+	}
+	return c.writeEvent(msg)
+}
+
+//check and handle event message
+//return true if it's ack or handled event
+func (c *Client) handleEvent(data []byte) bool {
+	if c.eventRouter == nil {
+		return false
+	}
+	msg := face.ParseEventMsg(data)
+	if msg == nil {
+		return false
+	}
+	if msg.Event == "" {
+		c.eventAcks.Resolve(msg)
+		return true
+	}
+	return c.eventRouter.Dispatch(c, msg, c.writeEvent)
+}
+
+//write event message as json text
+func (c *Client) writeEvent(msg *gvar.EventMsg) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.SendText(string(data))
+}
+
+//ack reliable message
+//return false if message is duplicated or not data message
+func (c *Client) handleReliable(msg *gvar.ReliableMsg) bool {
+	if msg.Rel != define.ReliableOfMsg {
+		return false
+	}
+	ack, _ := json.Marshal(&gvar.ReliableMsg{
+		Rel: define.ReliableOfAck,
+		Seq: msg.Seq,
+	})
+	if err := c.SendText(string(ack)); err != nil {
+		log.Printf("client write reliable ack failed, err:%v\n", err)
+	}
+	return !c.reliableWindow.Received(msg.Seq)
+}
+
+//get session token, empty if not issued
+func (c *Client) GetSessionToken() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.sessionToken
+}
+
+//check and handle session control message
+func (c *Client) handleSession(data []byte) bool {
+	if !c.enableResume || len(data) <= 0 || data[0] != '{' {
+		return false
+	}
+	msg := &gvar.ControlMsg{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return false
+	}
+	if msg.Ctrl != define.ControlOfSession && msg.Ctrl != define.ControlOfResumed {
+		return false
+	}
+	c.connMu.Lock()
+	if msg.Ctrl == define.ControlOfSession && c.sessionToken != "" && c.seqMap != nil {
+		//resume failed and new session issued, group seq may restart
+		c.resetSeq()
+	}
+	c.sessionToken = msg.Token
+	c.connMu.Unlock()
+	if msg.Ctrl == define.ControlOfResumed && c.OnResumed != nil {
+		c.OnResumed()
+	}
+	return true
+}
+
+//gen connect url with session token
+func (c *Client) genUrl() string {
+	token := c.GetSessionToken()
+	if token == "" {
+		return c.url
+	}
+	connUrl, err := url.Parse(c.url)
+	if err != nil {
+		return c.url
+	}
+	query := connUrl.Query()
+	query.Set(define.QueryParaOfSession, token)
+	connUrl.RawQuery = query.Encode()
+	return connUrl.String()
+}
+
+//subscribe topic pattern, server replies `subbed` or `error` control message
+func (c *Client) Subscribe(pattern string) error {
+	return c.writeControl(&gvar.ControlMsg{Ctrl: define.ControlOfSub, Topic: pattern})
+}
+
+//unsubscribe topic pattern
+func (c *Client) Unsubscribe(pattern string) error {
+	return c.writeControl(&gvar.ControlMsg{Ctrl: define.ControlOfUnsub, Topic: pattern})
+}
+
+//request history of group since seq, server replies `history` control message
+func (c *Client) RequestHistory(group string, sinceSeq int64) error {
+	return c.writeControl(&gvar.ControlMsg{
+		Ctrl:    define.ControlOfHistory,
+		Group:   group,
+		History: &gvar.HistoryQuery{SinceSeq: sinceSeq},
+	})
+}
+
+//check seq of group cast
+//return false if message is duplicated
+func (c *Client) handleSeq(msg *gvar.HistoryMsg) bool {
+	//zero seq not recorded by server
+	if msg.Seq <= 0 {
+		return true
+	}
+	c.connMu.Lock()
+	lastSeq := c.seqMap[msg.Group]
+	if msg.Seq <= lastSeq {
+		c.connMu.Unlock()
+		return false
+	}
+	c.seqMap[msg.Group] = msg.Seq
+	c.connMu.Unlock()
+	if lastSeq > 0 && msg.Seq > lastSeq+1 && c.OnGap != nil {
+		c.OnGap(msg.Group, lastSeq, msg.Seq)
+	}
+	return true
+}
+
+//reset seq of all groups without locker
+func (c *Client) resetSeq() {
+	for group := range c.seqMap {
+		delete(c.seqMap, group)
+	}
+}
+
+//reset seq of group when joined reply received
+//group may be recreated with seq restarted
+func (c *Client) resetSeqOfJoined(data []byte) {
+	if len(data) <= 0 || data[0] != '{' {
+		return
+	}
+	msg := &gvar.ControlMsg{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return
+	}
+	if msg.Ctrl != define.ControlOfJoined || msg.Group == "" {
+		return
+	}
+	c.connMu.Lock()
+	delete(c.seqMap, msg.Group)
+	c.connMu.Unlock()
+}
+
+//parse group cast envelope with seq
+//return nil if not seq message
+func parseSeqMsg(data []byte) *gvar.HistoryMsg {
+	if len(data) <= 0 || data[0] != '{' {
+		return nil
+	}
+	msg := &gvar.CastMsg{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil
+	}
+	if msg.Ctrl != define.ControlOfCast || msg.Group == "" || msg.Seq <= 0 || msg.Data == nil {
+		return nil
+	}
+	return &msg.HistoryMsg
+}
+
+//write control message as json text
+func (c *Client) writeControl(msg *gvar.ControlMsg) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.SendText(string(data))
+}
+
+//send close frame with status code and reason
+func (c *Client) sendClose(code int, reason string) error {
+	c.connMu.RLock()
+	conn := c.conn
+	c.connMu.RUnlock()
+	if conn == nil {
+		return errors.New("connect is nil")
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	return closeCodec.Send(conn, payload)
+}
+
+//reject message over max size or frames, close transport only
+func (c *Client) rejectTooBig() {
+	c.sendClose(closeCodeMessageTooBig, "message too big")
+	face.CloseTransport(c.conn)
+}
+
+//dial server with tap, to count size and frames of fragmented message
+func (c *Client) dialWithTap(config *websocket.Config) (*websocket.Conn, *face.CloseTap, error) {
+	//get host with default port
+	host := config.Location.Host
+	if config.Location.Port() == "" {
+		port := "80"
+		if config.Location.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(config.Location.Hostname(), port)
+	}
+
+	//dial raw connect
+	var (
+		rwc net.Conn
+		err error
+	)
+	switch config.Location.Scheme {
+	case "ws":
+		rwc, err = net.Dial("tcp", host)
+	case "wss":
+		rwc, err = tls.Dial("tcp", host, config.TlsConfig)
+	default:
+		err = websocket.ErrBadScheme
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//handshake through tap
+	tap := face.NewClientTap(rwc)
+	tap.SetMessageLimit(c.maxMessageSize, c.maxFramesPerMessage)
+	conn, err := websocket.NewClient(config, tap)
+	if err != nil {
+		rwc.Close()
+		return nil, nil, err
+	}
+	return conn, tap, nil
+}
+
 //handle error and reconnect
 func (c *Client) handleError(err error) {
 	if c.OnError != nil {
@@ -251,7 +734,13 @@ func (c *Client) Close() {
 		}
 		c.connMu.Unlock()
 
-		close(c.writeChan)
+		//write chan kept open, send after close returns by ctx done
+		if c.rpcPending != nil {
+			c.rpcPending.Quit()
+		}
+		if c.eventAcks != nil {
+			c.eventAcks.Quit()
+		}
 
 		if c.OnClose != nil {
 			c.OnClose()
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andyzhou/websocket"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * load generation and benchmark tool
+ * - open batch concurrent clients to target
+ * - send messages with assigned rate and size
+ * - report connect latency, rtt percentiles and throughput
+ *
+ * message payload format:
+ *   [8 bytes client idx][8 bytes send unix nano][padding]
+ */
+
+const (
+	PayloadHeadSize = 16
+	ProgressSeconds = 5
+)
+
+//command line options
+var (
+	wsUrl       = flag.String("url", "", "target websocket url, empty means start local server")
+	origin      = flag.String("origin", "http://localhost/", "origin of handshake")
+	conns       = flag.Int("conns", 1000, "total concurrent connections")
+	concurrency = flag.Int("concurrency", 100, "max connections dialing at the same time")
+	rate        = flag.Float64("rate", 1, "messages per second of each connection, zero means no send")
+	size        = flag.Int("size", 64, "message size in bytes")
+	duration    = flag.Duration("duration", 30*time.Second, "benchmark duration after all connected")
+	mode        = flag.String("mode", "router", "local server mode, router or dynamic")
+	buckets     = flag.Int("buckets", 4, "buckets of local router")
+	port        = flag.Int("port", 18080, "port of local server")
+	broadcast   = flag.Bool("broadcast", false, "local server broadcast messages to all instead of echo")
+)
+
+//one bench client
+type benchClient struct {
+	idx    uint64
+	client *websocket.Client
+	stats  *Stats
+}
+
+//gen payload
+func (f *benchClient) genPayload() []byte {
+	payloadSize := *size
+	if payloadSize < PayloadHeadSize {
+		payloadSize = PayloadHeadSize
+	}
+	payload := make([]byte, payloadSize)
+	binary.BigEndian.PutUint64(payload[0:8], f.idx)
+	binary.BigEndian.PutUint64(payload[8:16], uint64(time.Now().UnixNano()))
+	return payload
+}
+
+//message received
+func (f *benchClient) onMessage(messageType websocket.MessageType, data []byte) {
+	atomic.AddInt64(&f.stats.received, 1)
+	atomic.AddInt64(&f.stats.receivedBytes, int64(len(data)))
+	if len(data) < PayloadHeadSize {
+		return
+	}
+	if binary.BigEndian.Uint64(data[0:8]) != f.idx {
+		//message from other client
+		atomic.AddInt64(&f.stats.broadcasts, 1)
+		return
+	}
+	sendTime := int64(binary.BigEndian.Uint64(data[8:16]))
+	f.stats.rtt.add(time.Duration(time.Now().UnixNano() - sendTime))
+}
+
+//connect target
+func (f *benchClient) connect(url string) error {
+	f.client = websocket.NewClient(url, *origin, websocket.ClientOption{})
+	f.client.OnMessage = f.onMessage
+	f.client.OnError = func(err error) {
+		atomic.AddInt64(&f.stats.errors, 1)
+	}
+	f.client.OnClose = func() {
+		atomic.AddInt64(&f.stats.closed, 1)
+	}
+	begin := time.Now()
+	if err := f.client.Connect(); err != nil {
+		return err
+	}
+	f.stats.connectLatency.add(time.Since(begin))
+	return nil
+}
+
+//send loop
+func (f *benchClient) sendLoop(stop chan struct{}) {
+	if *rate <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			payload := f.genPayload()
+			if err := f.client.SendBinary(payload); err != nil {
+				atomic.AddInt64(&f.stats.errors, 1)
+				return
+			}
+			atomic.AddInt64(&f.stats.sent, 1)
+			atomic.AddInt64(&f.stats.sentBytes, int64(len(payload)))
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	//check or start local server
+	target := *wsUrl
+	if target == "" {
+		path, err := startLocalServer(*port, *mode, *buckets, *broadcast)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "start local server failed, err:%v\n", err)
+			os.Exit(1)
+		}
+		target = fmt.Sprintf("ws://127.0.0.1:%v%v", *port, path)
+		time.Sleep(time.Second)
+		fmt.Printf("local %v server started, target:%v\n", *mode, target)
+	}
+
+	//open connections
+	var (
+		wg      sync.WaitGroup
+		clients = make([]*benchClient, 0, *conns)
+		locker  sync.Mutex
+	)
+	stats := NewStats()
+	limiter := make(chan struct{}, *concurrency)
+	for i := 0; i < *conns; i++ {
+		wg.Add(1)
+		limiter <- struct{}{}
+		go func(idx int) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			bc := &benchClient{
+				idx:   uint64(idx),
+				stats: stats,
+			}
+			if err := bc.connect(target); err != nil {
+				atomic.AddInt64(&stats.connectFailed, 1)
+				return
+			}
+			atomic.AddInt64(&stats.connected, 1)
+			locker.Lock()
+			clients = append(clients, bc)
+			locker.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	fmt.Printf("connect finished, %v\n", stats.Progress())
+
+	//run send loops
+	stop := make(chan struct{})
+	stats.Reset()
+	for _, bc := range clients {
+		go bc.sendLoop(stop)
+	}
+
+	//wait duration or interrupt
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	timer := time.NewTimer(*duration)
+	ticker := time.NewTicker(ProgressSeconds * time.Second)
+	defer ticker.Stop()
+	func() {
+		for {
+			select {
+			case <-timer.C:
+				return
+			case <-interrupt:
+				return
+			case <-ticker.C:
+				fmt.Println(stats.Progress())
+			}
+		}
+	}()
+	close(stop)
+
+	//wait in flight messages and report
+	time.Sleep(time.Second)
+	fmt.Print(stats.Report())
+	for _, bc := range clients {
+		bc.client.Close()
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/andyzhou/websocket"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * local target server for benchmark
+ * - router mode, uri `/bench`
+ * - dynamic mode, uri `/bench/1`
+ */
+
+const (
+	BenchUri     = "/bench"
+	BenchGroupId = "1"
+)
+
+//gen echo or broadcast message
+func genBenchMsg(connId int64, data interface{}, broadcast bool) *gvar.MsgData {
+	msg := &gvar.MsgData{
+		Data:         data,
+		WriteInQueue: true,
+	}
+	if !broadcast {
+		msg.ConnIds = []int64{connId}
+	}
+	return msg
+}
+
+//start local server
+//return target url path
+func startLocalServer(port int, mode string, buckets int, broadcast bool) (string, error) {
+	s := websocket.NewServer()
+
+	if mode == "dynamic" {
+		cfg := s.GenGroupCfg()
+		cfg.Uri = BenchUri
+		cfg.MessageType = gvar.MessageTypeOfOctet
+		cfg.CBForRead = func(group interface{}, groupId string, connId int64, messageType int, data interface{}) error {
+			groupObj, _ := group.(iface.IGroup)
+			if groupObj == nil {
+				return nil
+			}
+			return groupObj.Cast(genBenchMsg(connId, data, broadcast))
+		}
+		dynamic, err := s.RegisterDynamic(cfg)
+		if err != nil {
+			return "", err
+		}
+		if _, err = dynamic.CreateGroup(BenchGroupId); err != nil {
+			return "", err
+		}
+		return BenchUri + "/" + BenchGroupId, s.Start(port)
+	}
+
+	cfg := s.GenRouterCfg()
+	cfg.Uri = BenchUri
+	cfg.Buckets = buckets
+	cfg.MessageType = gvar.MessageTypeOfOctet
+	cfg.CBForRead = func(router interface{}, bucketId int, connId int64, messageType int, data interface{}) error {
+		routerObj, _ := router.(iface.IRouter)
+		if routerObj == nil {
+			return nil
+		}
+		return routerObj.Cast(genBenchMsg(connId, data, broadcast))
+	}
+	if err := s.RegisterRouter(cfg); err != nil {
+		return "", err
+	}
+	return BenchUri, s.Start(port)
+}
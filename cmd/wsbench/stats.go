@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * benchmark statistics
+ */
+
+const (
+	MaxSamples = 100000 //max samples of each latency reservoir
+)
+
+//latency reservoir
+type latency struct {
+	samples []time.Duration
+	total   int64
+	sync.Mutex
+}
+
+//add one sample
+func (f *latency) add(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	f.total++
+	if len(f.samples) < MaxSamples {
+		f.samples = append(f.samples, d)
+		return
+	}
+	//reservoir sampling
+	idx := rand.Int63n(f.total)
+	if idx < MaxSamples {
+		f.samples[idx] = d
+	}
+}
+
+//format percentiles
+func (f *latency) String() string {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.samples) <= 0 {
+		return "no samples"
+	}
+	sorted := make([]time.Duration, len(f.samples))
+	copy(sorted, f.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	percentile := func(p float64) time.Duration {
+		idx := int(float64(len(sorted)-1) * p)
+		return sorted[idx]
+	}
+	return fmt.Sprintf("min:%v p50:%v p90:%v p99:%v p999:%v max:%v",
+		sorted[0], percentile(0.5), percentile(0.9), percentile(0.99),
+		percentile(0.999), sorted[len(sorted)-1])
+}
+
+//face info
+type Stats struct {
+	connectLatency latency
+	rtt            latency
+	connected      int64
+	connectFailed  int64
+	closed         int64
+	errors         int64
+	sent           int64
+	sentBytes      int64
+	received       int64
+	receivedBytes  int64
+	broadcasts     int64
+	startTime      time.Time
+}
+
+//construct
+func NewStats() *Stats {
+	this := &Stats{
+		startTime: time.Now(),
+	}
+	return this
+}
+
+//reset start time
+func (f *Stats) Reset() {
+	f.startTime = time.Now()
+}
+
+//one line progress
+func (f *Stats) Progress() string {
+	return fmt.Sprintf("conns:%v failed:%v closed:%v sent:%v received:%v broadcasts:%v errors:%v",
+		atomic.LoadInt64(&f.connected), atomic.LoadInt64(&f.connectFailed),
+		atomic.LoadInt64(&f.closed), atomic.LoadInt64(&f.sent),
+		atomic.LoadInt64(&f.received), atomic.LoadInt64(&f.broadcasts),
+		atomic.LoadInt64(&f.errors))
+}
+
+//final report
+func (f *Stats) Report() string {
+	seconds := time.Since(f.startTime).Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	sent := atomic.LoadInt64(&f.sent)
+	received := atomic.LoadInt64(&f.received)
+	return fmt.Sprintf(`
+connections:
+  connected:     %v
+  failed:        %v
+  closed:        %v
+  latency:       %v
+messages:
+  sent:          %v (%.1f msg/s, %.1f KB/s)
+  received:      %v (%.1f msg/s, %.1f KB/s)
+  broadcasts:    %v
+  rtt:           %v
+errors:          %v
+`,
+		atomic.LoadInt64(&f.connected), atomic.LoadInt64(&f.connectFailed),
+		atomic.LoadInt64(&f.closed), f.connectLatency.String(),
+		sent, float64(sent)/seconds, float64(atomic.LoadInt64(&f.sentBytes))/seconds/1024,
+		received, float64(received)/seconds, float64(atomic.LoadInt64(&f.receivedBytes))/seconds/1024,
+		atomic.LoadInt64(&f.broadcasts), f.rtt.String(),
+		atomic.LoadInt64(&f.errors))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andyzhou/websocket"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * wscat-style interactive websocket client
+ *
+ * interactive input:
+ *   <text>          send text message
+ *   /text <text>    send text message, keep leading `/`
+ *   /hex <hex>      send binary message from hex string
+ *   /b64 <base64>   send binary message from base64 string
+ *   /file <path>    send binary message from file content
+ *   /quit           close and exit
+ */
+
+const (
+	TimeFormat = "15:04:05.000"
+)
+
+//multi value flag
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(val string) error {
+	*f = append(*f, val)
+	return nil
+}
+
+//command line options
+var (
+	wsUrl         = flag.String("url", "", "websocket url, like ws://localhost:8080/ws")
+	origin        = flag.String("origin", "http://localhost/", "origin of handshake")
+	insecure      = flag.Bool("insecure", false, "skip tls certificate verification")
+	caFile        = flag.String("ca", "", "ca certificate file for tls")
+	certFile      = flag.String("cert", "", "client certificate file for tls")
+	keyFile       = flag.String("key", "", "client key file for tls")
+	reconnect     = flag.Bool("reconnect", false, "auto reconnect when connect lost")
+	heartbeat     = flag.Int("heartbeat", 0, "heartbeat seconds, zero means disabled")
+	scriptFile    = flag.String("script", "", "script file of send/expect steps")
+	expectTimeout = flag.Duration("timeout", 5*time.Second, "default timeout of expect step")
+	quiet         = flag.Bool("quiet", false, "don't print received messages")
+	headers       multiFlag
+	protocols     multiFlag
+)
+
+//print with timestamp
+func printMessage(direction string, messageType websocket.MessageType, data []byte) {
+	now := time.Now().Format(TimeFormat)
+	if messageType == websocket.BinaryMessage {
+		fmt.Printf("%v %v binary %v bytes\n%v", now, direction, len(data), hex.Dump(data))
+		return
+	}
+	fmt.Printf("%v %v %v\n", now, direction, string(data))
+}
+
+//parse header flags
+func parseHeaders() (http.Header, error) {
+	header := http.Header{}
+	for _, v := range headers {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid header %v", v)
+		}
+		header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return header, nil
+}
+
+//parse tls flags
+func parseTlsConfig() (*tls.Config, error) {
+	if !*insecure && *caFile == "" && *certFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: *insecure,
+	}
+	if *caFile != "" {
+		caData, err := os.ReadFile(*caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, errors.New("invalid ca certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+//parse input line into message
+//return messageType, data, isQuit, error
+func parseInput(line string) (websocket.MessageType, []byte, bool, error) {
+	if !strings.HasPrefix(line, "/") {
+		return websocket.TextMessage, []byte(line), false, nil
+	}
+	cmd, para, _ := strings.Cut(line, " ")
+	switch cmd {
+	case "/quit":
+		return websocket.TextMessage, nil, true, nil
+	case "/text":
+		return websocket.TextMessage, []byte(para), false, nil
+	case "/hex":
+		data, err := hex.DecodeString(strings.ReplaceAll(para, " ", ""))
+		return websocket.BinaryMessage, data, false, err
+	case "/b64":
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(para))
+		return websocket.BinaryMessage, data, false, err
+	case "/file":
+		data, err := os.ReadFile(strings.TrimSpace(para))
+		return websocket.BinaryMessage, data, false, err
+	default:
+		return websocket.TextMessage, nil, false, fmt.Errorf("unknown command %v", cmd)
+	}
+}
+
+//send message by type
+func sendMessage(client *websocket.Client, messageType websocket.MessageType, data []byte) error {
+	if messageType == websocket.BinaryMessage {
+		return client.SendBinary(data)
+	}
+	return client.SendText(string(data))
+}
+
+//interactive loop from stdin
+func interactive(client *websocket.Client, closed chan struct{}) {
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			if line == "" {
+				continue
+			}
+			messageType, data, isQuit, err := parseInput(line)
+			if isQuit {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "input error: %v\n", err)
+				continue
+			}
+			if err = sendMessage(client, messageType, data); err != nil {
+				fmt.Fprintf(os.Stderr, "send error: %v\n", err)
+				return
+			}
+		}
+	}
+}
+
+func main() {
+	flag.Var(&headers, "H", "extra handshake header `Key: Value`, repeatable")
+	flag.Var(&protocols, "subprotocol", "websocket sub protocol, repeatable")
+	flag.Parse()
+	if *wsUrl == "" && flag.NArg() > 0 {
+		*wsUrl = flag.Arg(0)
+	}
+	if *wsUrl == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	//setup client option
+	header, err := parseHeaders()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	tlsConfig, err := parseTlsConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	option := websocket.ClientOption{
+		Reconnect:            *reconnect,
+		ReconnectMax:         10,
+		ReconnectBaseSeconds: 2,
+		HeartbeatSeconds:     *heartbeat,
+		Header:               header,
+		Protocols:            protocols,
+		TlsConfig:            tlsConfig,
+	}
+
+	//init client
+	var script *Script
+	if *scriptFile != "" {
+		script, err = LoadScript(*scriptFile, *expectTimeout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	closed := make(chan struct{})
+	client := websocket.NewClient(*wsUrl, *origin, option)
+	client.OnMessage = func(messageType websocket.MessageType, data []byte) {
+		if !*quiet {
+			printMessage("<", messageType, data)
+		}
+		if script != nil {
+			script.Receive(messageType, data)
+		}
+	}
+	client.OnError = func(err error) {
+		fmt.Fprintf(os.Stderr, "%v error: %v\n", time.Now().Format(TimeFormat), err)
+	}
+	client.OnClose = func() {
+		fmt.Fprintf(os.Stderr, "%v closed\n", time.Now().Format(TimeFormat))
+		close(closed)
+	}
+	if err = client.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "connect %v failed, err:%v\n", *wsUrl, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "connected to %v, protocol:%v\n", *wsUrl, client.GetProtocol())
+
+	//run script or interactive mode
+	if script != nil {
+		err = script.Run(client, closed)
+		client.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "script failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "script passed")
+		return
+	}
+	interactive(client, closed)
+	client.Close()
+}
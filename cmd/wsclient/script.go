@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andyzhou/websocket"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * scripted send/expect steps for smoke tests
+ *
+ * one step per line, `#` for comment:
+ *   send <text>          send text message
+ *   sendhex <hex>        send binary message from hex string
+ *   sendb64 <base64>     send binary message from base64 string
+ *   sendfile <path>      send binary message from file content
+ *   expect <text>        wait message contains text
+ *   expect-re <regexp>   wait message matches regexp
+ *   expect-hex <hex>     wait binary message equal to hex data
+ *   timeout <duration>   setup timeout of later expect steps
+ *   sleep <duration>     pause a while
+ */
+
+const (
+	ScriptMessageChanSize = 1024
+)
+
+//one script step
+type step struct {
+	line   int
+	cmd    string
+	para   string
+	regExp *regexp.Regexp
+	data   []byte
+}
+
+//received message
+type received struct {
+	messageType websocket.MessageType
+	data        []byte
+}
+
+//face info
+type Script struct {
+	steps       []step
+	timeout     time.Duration
+	messageChan chan received
+}
+
+//load script from file
+func LoadScript(path string, timeout time.Duration) (*Script, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	this := &Script{
+		steps:       []step{},
+		timeout:     timeout,
+		messageChan: make(chan received, ScriptMessageChanSize),
+	}
+
+	//parse steps
+	lineNo := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, para, _ := strings.Cut(line, " ")
+		s := step{
+			line: lineNo,
+			cmd:  cmd,
+			para: strings.TrimSpace(para),
+		}
+		switch cmd {
+		case "send", "expect":
+		case "sendhex", "sendb64", "sendfile":
+			//convert into interactive command, like `/hex`
+			s.cmd = "/" + strings.TrimPrefix(cmd, "send")
+			if s.cmd != "/file" {
+				_, _, _, err = parseInput(s.cmd + " " + s.para)
+			}
+		case "expect-re":
+			s.regExp, err = regexp.Compile(s.para)
+		case "expect-hex":
+			s.data, err = hex.DecodeString(strings.ReplaceAll(s.para, " ", ""))
+		case "timeout", "sleep":
+			_, err = time.ParseDuration(s.para)
+		default:
+			err = fmt.Errorf("unknown step %v", cmd)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", lineNo, err)
+		}
+		this.steps = append(this.steps, s)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+//receive message from client
+func (f *Script) Receive(messageType websocket.MessageType, data []byte) {
+	select {
+	case f.messageChan <- received{messageType: messageType, data: data}:
+	default:
+		//queue full, drop the oldest
+		<-f.messageChan
+		f.messageChan <- received{messageType: messageType, data: data}
+	}
+}
+
+//run all steps
+func (f *Script) Run(client *websocket.Client, closed chan struct{}) error {
+	timeout := f.timeout
+	for _, s := range f.steps {
+		var err error
+		switch s.cmd {
+		case "send":
+			err = client.SendText(s.para)
+		case "/hex", "/b64", "/file":
+			messageType, data, _, subErr := parseInput(s.cmd + " " + s.para)
+			if subErr != nil {
+				err = subErr
+				break
+			}
+			err = sendMessage(client, messageType, data)
+		case "timeout":
+			timeout, _ = time.ParseDuration(s.para)
+		case "sleep":
+			duration, _ := time.ParseDuration(s.para)
+			time.Sleep(duration)
+		default:
+			err = f.expect(s, timeout, closed)
+		}
+		if err != nil {
+			return fmt.Errorf("line %v: %v", s.line, err)
+		}
+	}
+	return nil
+}
+
+//wait message until matched or timeout
+func (f *Script) expect(s step, timeout time.Duration, closed chan struct{}) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-closed:
+			return errors.New("connect closed")
+		case <-timer.C:
+			return fmt.Errorf("%v %v timeout after %v", s.cmd, s.para, timeout)
+		case msg := <-f.messageChan:
+			if f.isMatched(s, msg) {
+				return nil
+			}
+		}
+	}
+}
+
+//check message matched step or not
+func (f *Script) isMatched(s step, msg received) bool {
+	switch s.cmd {
+	case "expect-re":
+		return s.regExp.Match(msg.data)
+	case "expect-hex":
+		return bytes.Equal(s.data, msg.data)
+	default:
+		return bytes.Contains(msg.data, []byte(s.para))
+	}
+}
@@ -1,7 +1,48 @@
 package define
 
+import "time"
+
 const (
 	ConnWriteChanSize = 64
 	MessageChanSize   = 1024
 	ASyncWorkerNum    = 10
 )
+
+//websocket close status code
+const (
+	CloseCodeNormal          = 1000
+	CloseCodeGoingAway       = 1001
+	CloseCodeProtocolError   = 1002
+	CloseCodeUnsupportedData = 1003
+	CloseCodeNoStatus        = 1005
+	CloseCodeAbnormal        = 1006
+	CloseCodeBadData         = 1007
+	CloseCodePolicyViolation = 1008
+	CloseCodeMessageTooBig   = 1009
+	CloseCodeInternalError   = 1011
+	CloseCodeTryAgainLater   = 1013
+	CloseCodeTLSHandshake    = 1015
+	CloseCodeMax             = 4999
+	CloseReasonMaxSize       = 123 //max close reason bytes of control frame
+)
+
+const (
+	LimiterCleanSeconds = 60 //idle owner and ip token buckets clean rate
+)
+
+const (
+	OwnerLockerSlots = 64 //striped lockers of owner policy check and bind
+)
+
+const (
+	AdmissionSampleSeconds = 1 //memory stats sample rate
+)
+
+const (
+	IdleWheelTick  = time.Second //idle check timing wheel tick
+	IdleWheelSlots = 512
+)
+
+const (
+	DeliveryReportSeconds = 10 //max seconds of waiting delivery report
+)
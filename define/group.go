@@ -8,4 +8,28 @@ const (
 
 const (
 	PathParaNameOfGroup = "group"
+	GroupIdMaxSize      = 128 //max bytes of string group id
+	GroupJoinRetries    = 3   //join again if group reclaimed before joined
+)
+
+//control message of multi groups mode
+const (
+	ControlOfJoin   = "join"
+	ControlOfLeave  = "leave"
+	ControlOfJoined = "joined"
+	ControlOfLeft   = "left"
+	ControlOfError  = "error"
+)
+
+//group history
+const (
+	ControlOfHistory       = "history"
+	ControlOfCast          = "cast" //marker of group cast envelope with seq
+	DefaultHistoryMessages = 1024 //max messages per group of file history
+	HistoryFileSuffix      = ".history"
+
+	//query para names of history replay on connect
+	QueryParaOfHistoryLast = "history_last"
+	QueryParaOfHistorySeq  = "history_seq"
+	QueryParaOfHistoryTime = "history_time"
 )
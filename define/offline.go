@@ -0,0 +1,13 @@
+package define
+
+const (
+	DefaultOfflineMessages = 1024 //max kept messages per owner
+	OfflineCompactRecords  = 1024 //compact wal when garbage records over it
+)
+
+//wal record kind of offline store
+const (
+	OfflineOfPush      = "push"
+	OfflineOfPushFront = "front"
+	OfflineOfTake      = "take"
+)
@@ -0,0 +1,16 @@
+package define
+
+//topic format like `prices.BTC.usd`
+const (
+	TopicSeparator      = "."
+	TopicSingleWildcard = "*" //match one token
+	TopicMultiWildcard  = ">" //match one or more tail tokens
+)
+
+//control message of pub/sub
+const (
+	ControlOfSub      = "sub"
+	ControlOfUnsub    = "unsub"
+	ControlOfSubbed   = "subbed"
+	ControlOfUnsubbed = "unsubbed"
+)
@@ -0,0 +1,13 @@
+package define
+
+//reliable message kind
+const (
+	ReliableOfMsg = "msg"
+	ReliableOfAck = "ack"
+)
+
+const (
+	DefaultReliableMessages      = 1024 //max retained messages of one owner
+	DefaultReliableRetainSeconds = 300  //max seconds of retained message
+	ReliableWindowSize           = 1024 //received seq window of client for dedup
+)
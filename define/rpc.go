@@ -0,0 +1,16 @@
+package define
+
+//rpc message kind
+const (
+	RpcOfRequest  = "req"
+	RpcOfResponse = "resp"
+)
+
+//json rpc version
+const (
+	JsonRpcVersion = "2.0"
+)
+
+const (
+	EventAckSeconds = 30 //max seconds of waiting event ack
+)
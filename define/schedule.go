@@ -0,0 +1,8 @@
+package define
+
+import "time"
+
+const (
+	ScheduleWheelTick  = 100 * time.Millisecond //scheduled cast timing wheel tick
+	ScheduleWheelSlots = 600
+)
@@ -0,0 +1,13 @@
+package define
+
+const (
+	QueryParaOfSession    = "session" //query para name of resume token
+	SessionTokenSize      = 16        //random bytes of resume token
+	SessionMissedMessages = 1024      //max kept messages of parked connect
+)
+
+//control message of session resumption
+const (
+	ControlOfSession = "session" //new session token issued
+	ControlOfResumed = "resumed" //session resumed with token
+)
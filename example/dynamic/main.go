@@ -38,17 +38,17 @@ var (
 )
 
 //cb for closed
-func cbForClosed(group interface{}, groupId int64, connId int64) error {
+func cbForClosed(group interface{}, groupId string, connId int64, reason *gvar.CloseReason) error {
 	groupObj, _ := group.(iface.IGroup)
 	if groupObj == nil {
 		return errors.New("invalid group obj")
 	}
-	log.Printf("example.cbForClosed, groupId:%v, connId:%v\n", groupId, connId)
+	log.Printf("example.cbForClosed, groupId:%v, connId:%v, reason:%v\n", groupId, connId, reason)
 	return nil
 }
 
 //cb for connected
-func cbForConnected(group interface{}, groupId int64, connector interface{}) error {
+func cbForConnected(group interface{}, groupId string, connector interface{}) error {
 	groupObj, _ := group.(iface.IGroup)
 	if groupObj == nil {
 		return errors.New("invalid group obj")
@@ -71,13 +71,13 @@ func cbForConnected(group interface{}, groupId int64, connector interface{}) err
 }
 
 //cb for verify group
-func cbForVerifyGroup(conn *genWebsocket.Conn, group interface{}, groupId int64) error {
+func cbForVerifyGroup(conn *genWebsocket.Conn, group interface{}, groupId string) error {
 	log.Printf("example.cbForVerifyGroup, groupId:%v\n", groupId)
 	return nil
 }
 
 //cb for read data from client sent
-func cbForReadData(group interface{}, groupId int64, connId int64, messageType int, data interface{}) error {
+func cbForReadData(group interface{}, groupId string, connId int64, messageType int, data interface{}) error {
 	var (
 		msgData *gvar.MsgData
 	)
@@ -144,7 +144,7 @@ func main() {
 	}
 
 	//create group
-	group, subErr := dynamic.CreateGroup(1)
+	group, subErr := dynamic.CreateGroup("1")
 	if subErr != nil {
 		panic(any(subErr))
 	}
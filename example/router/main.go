@@ -28,12 +28,12 @@ var (
 )
 
 //cb for closed
-func cbForClosed(router interface{}, bucketId int, connId int64) error {
+func cbForClosed(router interface{}, bucketId int, connId int64, reason *gvar.CloseReason) error {
 	routerObj, _ := router.(iface.IRouter)
 	if routerObj == nil {
 		return errors.New("invalid router")
 	}
-	log.Printf("example.cbForClosed, bucketId:%v, connId:%v\n", bucketId, connId)
+	log.Printf("example.cbForClosed, bucketId:%v, connId:%v, reason:%v\n", bucketId, connId, reason)
 	return nil
 }
 
@@ -0,0 +1,156 @@
+package face
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * connect admission face
+ * - limit total connects and connects per remote ip
+ * - pluggable admission policy
+ */
+
+//rejected error, should reply http 503
+var ErrAdmissionRejected = errors.New("connect admission rejected")
+
+//face info
+type Admission struct {
+	maxConns      int
+	maxConnsPerIp int
+	policies      []func(req *http.Request) error
+	total         int64
+	ipMap         map[string]int //ip -> connects
+	locker        sync.Mutex
+	Util
+}
+
+//construct
+//return nil if no limit config
+func NewAdmission(maxConns, maxConnsPerIp int, policies ...func(req *http.Request) error) *Admission {
+	this := &Admission{
+		maxConns:      maxConns,
+		maxConnsPerIp: maxConnsPerIp,
+		ipMap:         map[string]int{},
+	}
+	for _, policy := range policies {
+		if policy != nil {
+			this.policies = append(this.policies, policy)
+		}
+	}
+	if maxConns <= 0 && maxConnsPerIp <= 0 && len(this.policies) <= 0 {
+		return nil
+	}
+	return this
+}
+
+//construct by server admission conf
+func NewAdmissionByConf(conf *gvar.AdmissionConf) *Admission {
+	if conf == nil {
+		return nil
+	}
+	return NewAdmission(conf.MaxConns, conf.MaxConnsPerIp,
+		NewResourcePolicy(conf.MaxGoroutines, conf.MaxMemoryBytes), conf.CBForAdmit)
+}
+
+//get total connects
+func (f *Admission) GetTotal() int64 {
+	return atomic.LoadInt64(&f.total)
+}
+
+//try acquire one connect slot
+//return release func if admitted
+func (f *Admission) Acquire(req *http.Request) (func(), error) {
+	//check policies
+	for _, policy := range f.policies {
+		if err := policy(req); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAdmissionRejected, err)
+		}
+	}
+
+	//check and update counters with locker
+	ip := f.GetRequestIp(req)
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	if f.maxConns > 0 && atomic.LoadInt64(&f.total) >= int64(f.maxConns) {
+		return nil, fmt.Errorf("%w: too many connects", ErrAdmissionRejected)
+	}
+	if f.maxConnsPerIp > 0 && ip != "" && f.ipMap[ip] >= f.maxConnsPerIp {
+		return nil, fmt.Errorf("%w: too many connects of ip %v", ErrAdmissionRejected, ip)
+	}
+	atomic.AddInt64(&f.total, 1)
+	if f.maxConnsPerIp > 0 && ip != "" {
+		f.ipMap[ip]++
+	}
+
+	//release func, run only once
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			f.release(ip)
+		})
+	}
+	return release, nil
+}
+
+//release one connect slot
+func (f *Admission) release(ip string) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	atomic.AddInt64(&f.total, -1)
+	if f.maxConnsPerIp <= 0 || ip == "" {
+		return
+	}
+	f.ipMap[ip]--
+	if f.ipMap[ip] <= 0 {
+		delete(f.ipMap, ip)
+	}
+}
+
+//gen admission policy by goroutines and memory
+//return nil if no threshold
+func NewResourcePolicy(maxGoroutines int, maxMemoryBytes uint64) func(req *http.Request) error {
+	var (
+		heapBytes  uint64
+		sampleTime int64
+		locker     sync.Mutex
+	)
+	if maxGoroutines <= 0 && maxMemoryBytes <= 0 {
+		return nil
+	}
+	return func(req *http.Request) error {
+		if maxGoroutines > 0 && runtime.NumGoroutine() > maxGoroutines {
+			return errors.New("too many goroutines")
+		}
+		if maxMemoryBytes <= 0 {
+			return nil
+		}
+
+		//sample memory stats, avoid stop the world frequently
+		now := time.Now().Unix()
+		if atomic.LoadInt64(&sampleTime)+define.AdmissionSampleSeconds <= now {
+			locker.Lock()
+			if atomic.LoadInt64(&sampleTime)+define.AdmissionSampleSeconds <= now {
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				atomic.StoreUint64(&heapBytes, stats.HeapAlloc)
+				atomic.StoreInt64(&sampleTime, now)
+			}
+			locker.Unlock()
+		}
+		if atomic.LoadUint64(&heapBytes) > maxMemoryBytes {
+			return errors.New("memory over threshold")
+		}
+		return nil
+	}
+}
@@ -29,22 +29,26 @@ type Bucket struct {
 	bucketId       int
 	router         iface.IRouter              //reference
 	conf           *gvar.RouterConf           //reference of parent router
+	shared         *Shared                    //shared components of parent router
 	connMap        map[int64]iface.IConnector //connId -> IConnector
-	connOwnerMap   map[int64]int64            //ownerId -> connId
+	connOwnerMap   ownerIndex                 //ownerId -> connIds
 	writeChan      chan gvar.MsgData
 	writeCloseChan chan bool
 	opts           int64
+	adding         int //connects in adding, counted by conns limit
 	locker         sync.RWMutex
 	Util
 }
 
 //construct
-func NewBucket(router iface.IRouter, bucketId int, cfg *gvar.RouterConf) *Bucket {
+func NewBucket(router iface.IRouter, bucketId int, cfg *gvar.RouterConf, shared *Shared) *Bucket {
 	this := &Bucket{
 		router: router,
 		bucketId: bucketId,
 		conf: cfg,
+		shared: shared,
 		connMap: map[int64]iface.IConnector{},
+		connOwnerMap: ownerIndex{},
 		writeChan: make(chan gvar.MsgData, define.DefaultBucketWriteChan),
 		writeCloseChan: make(chan bool, 1),
 	}
@@ -60,15 +64,23 @@ func (f *Bucket) Quit() {
 		close(f.writeCloseChan)
 	}
 
-	//force close with locker
+	//release connect map with locker
 	f.locker.Lock()
-	defer f.locker.Unlock()
-	for _, v := range f.connMap {
-		if v != nil {
-			v.Close()
+	connMap := f.connMap
+	f.connMap = nil
+	f.locker.Unlock()
+
+	//force close connects and notify outside
+	for connId, v := range connMap {
+		if v == nil {
+			continue
+		}
+		v.CloseWithCode(define.CloseCodeGoingAway, "server shutdown")
+		f.shared.ReleaseConn(v)
+		if f.conf != nil && f.conf.CBForClosed != nil {
+			f.conf.CBForClosed(f.router, f.bucketId, connId, v.GetCloseReason())
 		}
 	}
-	f.connMap = nil
 }
 
 //broadcast to connections by condition
@@ -92,6 +104,18 @@ func (f *Bucket) Broadcast(data *gvar.MsgData) error {
 	return nil
 }
 
+//broadcast and wait delivery report
+func (f *Bucket) BroadcastWithReport(data *gvar.MsgData) (*gvar.DeliveryReport, error) {
+	return castWithReport(f.Broadcast, data)
+}
+
+//get total connects
+func (f *Bucket) GetTotal() int {
+	f.locker.RLock()
+	defer f.locker.RUnlock()
+	return len(f.connMap)
+}
+
 //set conn owner id
 func (f *Bucket) SetOwner(connId, ownerId int64) error {
 	//check
@@ -108,45 +132,63 @@ func (f *Bucket) SetOwner(connId, ownerId int64) error {
 		return errors.New("can't get connector by id")
 	}
 
-	//set connect owner
-	connector.SetOwnerId(ownerId)
+	//set owner and conn id, bind owner without locker
+	oldOwnerId := f.setOwnerIndex(connector, ownerId)
+	f.shared.BindOwner(oldOwnerId, ownerId, connector)
+	return nil
+}
 
-	//set owner and conn id
+//set owner of connector and update owner index with locker
+//return old owner id, bind owner should be called after it
+func (f *Bucket) setOwnerIndex(connector iface.IConnector, ownerId int64) int64 {
+	connId := connector.GetConnId()
 	f.locker.Lock()
 	defer f.locker.Unlock()
-	f.connOwnerMap[ownerId] = connId
-	return nil
+	oldOwnerId := connector.GetOwnerId()
+	if oldOwnerId > 0 {
+		f.connOwnerMap.remove(oldOwnerId, connId)
+	}
+	connector.SetOwnerId(ownerId)
+	f.connOwnerMap.add(ownerId, connId, time.Now().UnixNano())
+	return oldOwnerId
 }
 
 //close old connect
+//connect lost by network will be parked if session resume enabled
 func (f *Bucket) CloseConn(connId int64) error {
 	//check
 	if connId <= 0 {
 		return errors.New("invalid parameter")
 	}
-
-	//check and call the closed cb of outside
-	if f.conf != nil && f.conf.CBForClosed != nil {
-		f.conf.CBForClosed(f.router, f.bucketId, connId)
-	}
-
-	//get conn by id
-	connector, _ := f.GetConn(connId)
-	if connector != nil {
-		connOwnerId := connector.GetOwnerId()
-		if connOwnerId > 0 {
-			f.locker.Lock()
-			delete(f.connOwnerMap, connOwnerId)
-			f.locker.Unlock()
-		}
-		//force close connect
-		connector.Close()
+	if f.parkConn(connId) {
+		return nil
 	}
+	return f.closeConn(connId)
+}
 
+//remove and close connect
+func (f *Bucket) closeConn(connId int64) error {
 	//remove conn from map
 	f.locker.Lock()
+	connector, ok := f.connMap[connId]
 	delete(f.connMap, connId)
+	if ok && connector != nil && connector.GetOwnerId() > 0 {
+		f.connOwnerMap.remove(connector.GetOwnerId(), connId)
+	}
 	f.locker.Unlock()
+	if !ok || connector == nil {
+		//closed before
+		return errors.New("no such connector")
+	}
+
+	//force close connect
+	connector.Close()
+	f.shared.ReleaseConn(connector)
+
+	//check and call the closed cb of outside
+	if f.conf != nil && f.conf.CBForClosed != nil {
+		f.conf.CBForClosed(f.router, f.bucketId, connId, connector.GetCloseReason())
+	}
 
 	//atomic opt
 	atomic.AddInt64(&f.opts, 1)
@@ -184,7 +226,7 @@ func (f *Bucket) RemoveConn(connId int64) (iface.IConnector, error) {
 	delete(f.connMap, connId)
 
 	if connector.GetOwnerId() > 0 {
-		delete(f.connOwnerMap, connector.GetOwnerId())
+		f.connOwnerMap.remove(connector.GetOwnerId(), connId)
 	}
 	f.locker.Unlock()
 
@@ -205,26 +247,36 @@ func (f *Bucket) RemoveConn(connId int64) (iface.IConnector, error) {
 	return connector, nil
 }
 
-//get connector by owner id
+//get newest connector by owner id
 func (f *Bucket) GetConnByOwnerId(ownerId int64) (iface.IConnector, error) {
-	var (
-		targetConnector iface.IConnector
-	)
+	connectors, err := f.GetConnsByOwnerId(ownerId)
+	if err != nil {
+		return nil, err
+	}
+	return connectors[0], nil
+}
+
+//get all connectors by owner id, newest first
+func (f *Bucket) GetConnsByOwnerId(ownerId int64) ([]iface.IConnector, error) {
 	//check
 	if ownerId <= 0 {
 		return nil, errors.New("invalid parameter")
 	}
 
-	//loop map to found
-	f.locker.RLocker()
+	//get by owner index with locker
+	f.locker.RLock()
 	defer f.locker.RUnlock()
-	for _, v := range f.connMap {
-		if v != nil && v.GetOwnerId() == ownerId {
-			targetConnector = v
-			break
+	connectors := make([]iface.IConnector, 0)
+	for _, connId := range f.connOwnerMap.getConnIds(ownerId) {
+		v, ok := f.connMap[connId]
+		if ok && v != nil {
+			connectors = append(connectors, v)
 		}
 	}
-	return targetConnector, nil
+	if len(connectors) <= 0 {
+		return nil, errors.New("no connector by owner id")
+	}
+	return connectors, nil
 }
 
 //get old connect
@@ -254,8 +306,11 @@ func (f *Bucket) AttachConn(connector iface.IConnector) error {
 	//sync into bucket map with locker
 	f.locker.Lock()
 	defer f.locker.Unlock()
-	connector.SetConfId(f.bucketId, 0)
+	connector.SetConfId(f.bucketId, "")
 	f.connMap[connector.GetConnId()] = connector
+	if connector.GetOwnerId() > 0 {
+		f.connOwnerMap.add(connector.GetOwnerId(), connector.GetConnId(), time.Now().UnixNano())
+	}
 	return nil
 }
 
@@ -266,6 +321,41 @@ func (f *Bucket) AddConn(connId int64, conn *websocket.Conn, timeouts ...time.Du
 		return errors.New("invalid parameter")
 	}
 
+	//check conns limit and reserve with locker
+	f.locker.Lock()
+	if f.conf != nil && f.conf.MaxConnsPerBucket > 0 && len(f.connMap)+f.adding >= f.conf.MaxConnsPerBucket {
+		f.locker.Unlock()
+		f.CloseConnWithCode(conn, define.CloseCodeTryAgainLater, "bucket is full")
+		return errors.New("bucket is full")
+	}
+	f.adding++
+	f.locker.Unlock()
+
+	//init new connector
+	connector := f.newConnector(connId, conn, timeouts...)
+
+	//check and call the connected cb of outside
+	if f.conf != nil && f.conf.CBForConnected != nil {
+		f.conf.CBForConnected(f.router, f.bucketId, connector)
+	}
+
+	//sync into bucket map with locker
+	f.locker.Lock()
+	f.adding--
+	f.connMap[connId] = connector
+	f.locker.Unlock()
+
+	//issue session token for resume
+	f.shared.IssueSession(connector)
+	return nil
+}
+
+////////////////
+//private func
+////////////////
+
+//init new connector with bucket config
+func (f *Bucket) newConnector(connId int64, conn *websocket.Conn, timeouts ...time.Duration) *Connector {
 	//setup connect config
 	cbForRead := func(connId int64, messageType int, data interface{}) error {
 		if f.conf.CBForRead != nil {
@@ -276,47 +366,80 @@ func (f *Bucket) AddConn(connId int64, conn *websocket.Conn, timeouts ...time.Du
 	cbForClose := func(connId int64) error {
 		return f.CloseConn(connId)
 	}
+	cbForTooBig := func(connId int64) error {
+		if f.conf.CBForTooBig != nil {
+			return f.conf.CBForTooBig(f.router, f.bucketId, connId)
+		}
+		return nil
+	}
+	cbForLimited := func(connId int64, kind int) error {
+		if f.conf.CBForLimited != nil {
+			return f.conf.CBForLimited(f.router, f.bucketId, connId, kind)
+		}
+		return nil
+	}
 	connConf := &ConnConf{
 		BucketId: f.bucketId,
 		MessageType: f.conf.MessageType,
+		MaxMessageSize: f.conf.MaxMessageSize,
+		MaxFramesPerMessage: f.conf.MaxFramesPerMessage,
 		CBForRead: cbForRead,
 		CBForClosed: cbForClose,
-	}
-
-	//init new connector
-	connector := NewConnector(connConf, connId, conn, timeouts...)
+		CBForTooBig: cbForTooBig,
+		CBForLimited: cbForLimited,
+		Limiter: f.shared.Limiter,
+		Wheel: f.shared.Wheel,
+		IdleTimeout: f.conf.IdleTimeout,
+		IdleMode: f.conf.IdleMode,
+		PingInterval: f.conf.PingInterval,
+		Rpc: f.shared.Rpc,
+		JsonRpc: f.shared.JsonRpc,
+		Event: f.shared.Event,
+		PubSub: f.shared.GetPubSub(f.conf.EnablePubSub),
+		Reliable: f.shared.Reliable,
+		OrderedWrite: f.conf.OrderedWrite,
+	}
+
+	return NewConnector(connConf, connId, conn, timeouts...)
+}
 
-	//check and call the connected cb of outside
-	if f.conf != nil && f.conf.CBForConnected != nil {
-		f.conf.CBForConnected(f.router, f.bucketId, connector)
+//park connect lost by network, closed when grace window expired
+func (f *Bucket) parkConn(connId int64) bool {
+	if f.shared.Sessions == nil {
+		return false
 	}
-
-	//sync into bucket map with locker
-	f.locker.Lock()
-	defer f.locker.Unlock()
-	f.connMap[connId] = connector
-	return nil
+	connector, _ := f.GetConn(connId)
+	if connector == nil {
+		return false
+	}
+	return f.shared.Sessions.Park(connector, f.resumeConn, func() {
+		f.closeConn(connId)
+	})
 }
 
-////////////////
-//private func
-////////////////
+//resume parked connector with new connect
+func (f *Bucket) resumeConn(old iface.IConnector, conn *websocket.Conn) (iface.IConnector, error) {
+	//init new connector with same conn id
+	connId := old.GetConnId()
+	connector := f.newConnector(connId, conn)
+	inheritConn(old, connector)
 
-//get conn id by owner id
-func (f *Bucket) getConnIdByOwnerId(ownerId int64) (int64, error) {
-	//check
-	if ownerId <= 0 {
-		return 0, errors.New("invalid parameter")
+	//replace parked connector with locker
+	f.locker.Lock()
+	if _, ok := f.connMap[connId]; !ok {
+		f.locker.Unlock()
+		connector.CloseWithCode(define.CloseCodeGoingAway, "session closed")
+		return nil, errors.New("no such connector")
 	}
+	f.connMap[connId] = connector
+	f.locker.Unlock()
 
-	//get connect id with locker
-	f.locker.RLock()
-	defer f.locker.RUnlock()
-	v, ok := f.connOwnerMap[ownerId]
-	if ok && v > 0 {
-		return v, nil
+	//take over shared components and notify outside
+	f.shared.ResumeConn(old, connector)
+	if f.conf != nil && f.conf.CBForResumed != nil {
+		f.conf.CBForResumed(f.router, f.bucketId, connector)
 	}
-	return 0, errors.New("no connect id by owner id")
+	return connector, nil
 }
 
 //sub write message opt
@@ -326,8 +449,22 @@ func (f *Bucket) subWriteOpt(data *gvar.MsgData) error {
 		return errors.New("invalid parameter")
 	}
 
-	//check byte data
+	//check byte data and filter
 	byteData, _ := data.Data.([]byte)
+	filter := newCastFilter(data)
+	recorder := newDeliveryRecorder()
+	defer recorder.finish(data)
+
+	//sub func for write one connect
+	writeConn := func(conn iface.IConnector) {
+		var err error
+		if data.WriteInQueue {
+			err = conn.QueueWrite(byteData)
+		}else{
+			err = conn.Write(data.Data, f.conf.MessageType)
+		}
+		recorder.record(conn.GetConnId(), err)
+	}
 
 	f.locker.Lock()
 	defer f.locker.Unlock()
@@ -337,20 +474,19 @@ func (f *Bucket) subWriteOpt(data *gvar.MsgData) error {
 			if ownerId <= 0 {
 				continue
 			}
-			connId, _ := f.getConnIdByOwnerId(ownerId)
-			if connId <= 0 {
-				continue
-			}
-			conn, ok := f.connMap[connId]
-			if !ok || conn == nil {
-				continue
-			}
-
-			//write to target conn
-			if data.WriteInQueue {
-				conn.QueueWrite(byteData)
-			}else{
-				conn.Write(data.Data, f.conf.MessageType)
+			//write to all connects of owner
+			for _, connId := range f.connOwnerMap.getConnIds(ownerId) {
+				conn, ok := f.connMap[connId]
+				if !ok || conn == nil {
+					continue
+				}
+				recorder.foundOwner(ownerId)
+				if !filter.allow(conn) {
+					continue
+				}
+
+				//write to target conn
+				writeConn(conn)
 			}
 		}
 	}
@@ -366,25 +502,27 @@ func (f *Bucket) subWriteOpt(data *gvar.MsgData) error {
 			if !ok || conn == nil {
 				continue
 			}
+			recorder.foundConn(connId)
+			if !filter.allow(conn) {
+				continue
+			}
 
 			//write to target conn
-			if data.WriteInQueue {
-				conn.QueueWrite(byteData)
-			}else{
-				conn.Write(data.Data, f.conf.MessageType)
-			}
+			writeConn(conn)
 		}
+	}
+	if len(data.OwnerIds) > 0 || len(data.ConnIds) > 0 {
+		//only send to assigned owners or connects
 		return nil
 	}
 
 	//send to all connects
 	for _, conn := range f.connMap {
-		//write to target conn
-		if data.WriteInQueue {
-			conn.QueueWrite(byteData)
-		}else{
-			conn.Write(data.Data, f.conf.MessageType)
+		if !filter.allow(conn) {
+			continue
 		}
+		//write to target conn
+		writeConn(conn)
 	}
 	return nil
 }
@@ -448,11 +586,7 @@ func (f *Bucket) rebuild() {
 	}
 	f.connMap = newConnMap
 
-	newOwnerMap := map[int64]int64{}
-	for k, v := range f.connOwnerMap {
-		newOwnerMap[k] = v
-	}
-	f.connOwnerMap = newOwnerMap
+	f.connOwnerMap = f.connOwnerMap.clone()
 	f.locker.Unlock()
 
 	atomic.StoreInt64(&f.opts, 0)
@@ -0,0 +1,389 @@
+package face
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"golang.org/x/net/websocket"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * close frame tap of hijacked connect
+ * - x/net websocket return io.EOF for close frame and drop the payload,
+ *   so tap read stream to get close code and reason sent by client
+ * - no close frame before eof means connect lost
+ * - count size and frames of fragmented message, x/net deliver it frame by frame
+ * - client tap skip http response of handshake before frames
+ * - notify pong payload, x/net drop it
+ */
+
+const (
+	continuationOpCode  = 0x00
+	textOpCode          = 0x01
+	binaryOpCode        = 0x02
+	closeOpCode         = 0x08
+	pongOpCode          = 0x0a
+	closeMaxPayloadSize = 125 //max payload bytes of control frame
+)
+
+//context key of close tap
+type closeTapKey struct{}
+
+//face info
+type CloseTap struct {
+	net.Conn
+	header  []byte //header bytes of current frame
+	mask    []byte
+	opCode  byte
+	remain  int64 //remain payload bytes of current frame
+	inFrame bool
+	ctrlBuf []byte //payload of close or pong frame
+	closed  bool   //close frame received
+	code    int
+	reason  string
+	readErr error //underlying read error, timeout skipped
+	onPong  func(payload []byte)
+
+	//http response of client handshake
+	handshake bool
+	hsTail    []byte //tail bytes for split terminator
+
+	//limits of fragmented message, zero means no limit
+	maxSize   int64
+	maxFrames int
+	msgSize   int64 //received bytes of current message
+	msgFrames int   //received frames of current message
+	frames    int64 //received data frames
+	tooBigAt  int64 //data frame over limits, zero means not found
+	sync.Mutex
+}
+
+//tap response writer, hijack connect with close tap
+type tapResponseWriter struct {
+	http.ResponseWriter
+	tap *CloseTap
+}
+
+//wrap http request and response writer with close tap
+//used before websocket handshake
+func WrapCloseTap(w http.ResponseWriter, req *http.Request) (http.ResponseWriter, *http.Request) {
+	tap := &CloseTap{}
+	ctx := context.WithValue(req.Context(), closeTapKey{}, tap)
+	return &tapResponseWriter{ResponseWriter: w, tap: tap}, req.WithContext(ctx)
+}
+
+//new tap of client connect, used before client handshake
+func NewClientTap(conn net.Conn) *CloseTap {
+	return &CloseTap{
+		Conn:      conn,
+		handshake: true,
+	}
+}
+
+//get close tap of websocket connect, nil if not wrapped
+func GetCloseTap(conn *websocket.Conn) *CloseTap {
+	if conn == nil || conn.Request() == nil {
+		return nil
+	}
+	tap, _ := conn.Request().Context().Value(closeTapKey{}).(*CloseTap)
+	if tap == nil || tap.Conn == nil {
+		return nil
+	}
+	return tap
+}
+
+//hijack connect and read through tap
+func (w *tapResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.tap.Conn = conn
+
+	//keep bytes buffered by http server
+	var reader io.Reader = w.tap
+	if size := buf.Reader.Buffered(); size > 0 {
+		buffered, _ := buf.Reader.Peek(size)
+		buffered = append([]byte{}, buffered...)
+		w.tap.Lock()
+		w.tap.parse(buffered)
+		w.tap.Unlock()
+		reader = io.MultiReader(bytes.NewReader(buffered), w.tap)
+	}
+	return w.tap, bufio.NewReadWriter(bufio.NewReader(reader), buf.Writer), nil
+}
+
+//read and parse frames
+func (f *CloseTap) Read(p []byte) (int, error) {
+	n, err := f.Conn.Read(p)
+	f.Lock()
+	defer f.Unlock()
+	f.parse(p[:n])
+	if err != nil {
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			f.readErr = err
+		}
+	}
+	return n, err
+}
+
+//get close code and reason sent by client
+//return false if close frame not received
+func (f *CloseTap) GetClose() (int, string, bool) {
+	f.Lock()
+	defer f.Unlock()
+	return f.code, f.reason, f.closed
+}
+
+//set limits of fragmented message, zero means no limit
+//size and frames counted across continuation frames
+func (f *CloseTap) SetMessageLimit(maxSize, maxFrames int) {
+	f.Lock()
+	defer f.Unlock()
+	f.maxSize = int64(maxSize)
+	f.maxFrames = maxFrames
+}
+
+//set pong handler, run in read goroutine with tap locker
+func (f *CloseTap) SetPongHandler(cb func(payload []byte)) {
+	f.Lock()
+	defer f.Unlock()
+	f.onPong = cb
+}
+
+//check message over limits received
+//frames is count of data frames read by caller, frames read ahead skipped
+func (f *CloseTap) IsTooBig(frames int64) bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.tooBigAt > 0 && frames >= f.tooBigAt
+}
+
+//gen close reason of read eof
+func (f *CloseTap) GenCloseReason(err error) *gvar.CloseReason {
+	f.Lock()
+	defer f.Unlock()
+	switch {
+	case f.closed:
+		return &gvar.CloseReason{
+			Code:      f.code,
+			Reason:    f.reason,
+			Initiator: gvar.CloseByClient,
+			Err:       err,
+		}
+	case f.readErr != nil:
+		return &gvar.CloseReason{
+			Code:      define.CloseCodeAbnormal,
+			Reason:    "connect lost",
+			Initiator: gvar.CloseByNetwork,
+			Err:       f.readErr,
+		}
+	default:
+		//bad frame rejected by server
+		return &gvar.CloseReason{
+			Code:      define.CloseCodeProtocolError,
+			Reason:    "protocol error",
+			Initiator: gvar.CloseByServer,
+			Err:       err,
+		}
+	}
+}
+
+//parse frames without locker
+func (f *CloseTap) parse(data []byte) {
+	//skip http response of client handshake
+	if f.handshake {
+		buf := append(f.hsTail, data...)
+		idx := bytes.Index(buf, []byte("\r\n\r\n"))
+		if idx < 0 {
+			if len(buf) > 3 {
+				buf = buf[len(buf)-3:]
+			}
+			f.hsTail = append([]byte{}, buf...)
+			return
+		}
+		f.handshake = false
+		f.hsTail = nil
+		data = buf[idx+4:]
+	}
+	for len(data) > 0 {
+		//collect frame header
+		if !f.inFrame {
+			need := 2
+			if len(f.header) >= 2 {
+				need = frameHeaderSize(f.header)
+			}
+			size := need - len(f.header)
+			if size > len(data) {
+				size = len(data)
+			}
+			f.header = append(f.header, data[:size]...)
+			data = data[size:]
+			if len(f.header) >= 2 && len(f.header) == frameHeaderSize(f.header) {
+				f.beginFrame()
+			}
+			continue
+		}
+
+		//consume payload
+		size := int64(len(data))
+		if size > f.remain {
+			size = f.remain
+		}
+		if f.opCode == closeOpCode || f.opCode == pongOpCode {
+			keep := closeMaxPayloadSize - len(f.ctrlBuf)
+			if int64(keep) > size {
+				keep = int(size)
+			}
+			if keep > 0 {
+				f.ctrlBuf = append(f.ctrlBuf, data[:keep]...)
+			}
+		}
+		data = data[size:]
+		f.remain -= size
+		if f.remain <= 0 {
+			f.endFrame()
+		}
+	}
+}
+
+//begin frame after header collected
+func (f *CloseTap) beginFrame() {
+	header := f.header
+	f.opCode = header[0] & 0x0f
+	pos := 2
+	switch length := header[1] & 0x7f; length {
+	case 126:
+		f.remain = int64(binary.BigEndian.Uint16(header[2:4]))
+		pos += 2
+	case 127:
+		f.remain = int64(binary.BigEndian.Uint64(header[2:10]))
+		pos += 8
+	default:
+		f.remain = int64(length)
+	}
+	f.mask = nil
+	if header[1]&0x80 != 0 {
+		f.mask = header[pos : pos+4]
+	}
+	f.inFrame = true
+	f.ctrlBuf = nil
+	f.countFrame()
+	if f.remain <= 0 {
+		f.endFrame()
+	}
+}
+
+//count size and frames of current message
+func (f *CloseTap) countFrame() {
+	switch f.opCode {
+	case textOpCode, binaryOpCode:
+		f.msgSize = f.remain
+		f.msgFrames = 1
+	case continuationOpCode:
+		f.msgSize += f.remain
+		f.msgFrames++
+	default:
+		//control frame between fragments
+		return
+	}
+	f.frames++
+	if f.tooBigAt > 0 {
+		return
+	}
+	if (f.maxSize > 0 && f.msgSize > f.maxSize) ||
+		(f.maxFrames > 0 && f.msgFrames > f.maxFrames) {
+		f.tooBigAt = f.frames
+	}
+}
+
+//end frame, keep close code and reason, or notify pong
+func (f *CloseTap) endFrame() {
+	payload := f.ctrlBuf
+	if f.mask != nil {
+		for i := range payload {
+			payload[i] ^= f.mask[i%4]
+		}
+	}
+	if f.opCode == pongOpCode && f.onPong != nil {
+		f.onPong(payload)
+	}
+	if f.opCode == closeOpCode && !f.closed {
+		f.closed = true
+		f.code = define.CloseCodeNoStatus
+		if len(payload) >= 2 {
+			f.code = int(binary.BigEndian.Uint16(payload))
+			f.reason = string(payload[2:])
+		}
+	}
+	f.header = nil
+	f.inFrame = false
+	f.ctrlBuf = nil
+}
+
+//get header size of frame by first two bytes
+func frameHeaderSize(header []byte) int {
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		size += 4
+	}
+	return size
+}
+
+//check close code can be sent on the wire
+func IsSendableCloseCode(code int) bool {
+	if code < define.CloseCodeNormal || code > define.CloseCodeMax {
+		return false
+	}
+	switch code {
+	case define.CloseCodeNoStatus, define.CloseCodeAbnormal, define.CloseCodeTLSHandshake:
+		return false
+	}
+	return true
+}
+
+//truncate close reason on rune boundary
+func TruncateCloseReason(reason string) string {
+	if len(reason) <= define.CloseReasonMaxSize {
+		return reason
+	}
+	size := define.CloseReasonMaxSize
+	for size > 0 && !utf8.RuneStart(reason[size]) {
+		size--
+	}
+	return reason[:size]
+}
+
+//close transport only, close frame sent before
+//x/net close always write its own close frame, fail it by past deadline
+func CloseTransport(conn *websocket.Conn) error {
+	if conn == nil {
+		return errors.New("invalid parameter")
+	}
+	conn.SetWriteDeadline(time.Now().Add(-time.Second))
+	conn.Close()
+	return nil
+}
@@ -1,6 +1,9 @@
 package face
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +11,7 @@ import (
 	"net"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andyzhou/websocket/define"
@@ -22,14 +26,48 @@ import (
  * websocket connect face
  */
 
+//codec for sending close frame
+var closeFrameCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		data, _ := v.([]byte)
+		return data, websocket.CloseFrame, nil
+	},
+}
+
+//codec for sending ping frame
+var pingFrameCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		data, _ := v.([]byte)
+		return data, websocket.PingFrame, nil
+	},
+}
+
+//invalid message data error, connect still available
+var errInvalidMessage = errors.New("invalid message data")
+
 //connect config
 type ConnConf struct {
-	BucketId       int
-	GroupId        int64
-	AsyncWorkerNum int
-	MessageType    int
-	CBForClosed    func(connId int64) error
-	CBForRead      func(connId int64, messageType int, data interface{}) error
+	BucketId            int
+	GroupId             string
+	AsyncWorkerNum      int
+	MessageType         int
+	MaxMessageSize      int //max bytes of one received message
+	MaxFramesPerMessage int //max frames of one received fragmented message
+	CBForClosed         func(connId int64) error
+	CBForRead           func(connId int64, messageType int, data interface{}) error
+	CBForTooBig         func(connId int64) error
+	CBForLimited        func(connId int64, kind int) error
+	Limiter             *RateLimiter //shared inbound rate limiter
+	Wheel               *TimingWheel //shared idle check timing wheel
+	IdleTimeout         time.Duration
+	IdleMode            int
+	PingInterval        time.Duration //send ping frame and measure rtt by pong, zero means disabled
+	Rpc                 *RpcRouter     //shared rpc method handlers, nil means rpc disabled
+	JsonRpc             *JsonRpcRouter //shared json rpc 2.0 handlers, nil means disabled
+	Event               *EventRouter   //shared event handlers, nil means disabled
+	PubSub              *PubSub        //shared topic pub/sub, nil means sub control disabled
+	Reliable            *Reliable      //shared reliable delivery, nil means disabled
+	OrderedWrite        bool           //all writes pass write queue in call order
 }
 
 //face info
@@ -39,6 +77,13 @@ type Connector struct {
 	ownerId          int64
 	activeTime       int64
 	conn             *websocket.Conn //origin conn reference
+	tap              *CloseTap       //tap of hijacked connect, nil if not wrapped
+	readFrames       int64           //read data frames, only used by read process
+	remoteIp         string
+	connBucket       *TokenBucket //token bucket of rate limit
+	rpcPending       *RpcPending  //pending rpc calls to client
+	eventAcks        *EventAcks   //ack callbacks of emitted events
+	missed           *missedQueue //kept messages after parked
 	propertyMap      map[string]interface{}
 	writeChan        chan interWriteData //write byte chan
 	closeChan        chan bool
@@ -50,15 +95,36 @@ type Connector struct {
 	readDeadline     time.Time
 	writeDeadline    time.Time
 	closeOnce        sync.Once
+	closeSent        int32 //close frame with code sent, atomic opt
+	stats            connStats //inter statistics
 	propLocker       sync.RWMutex
 	connLocker       sync.RWMutex
 	deadlineLocker   sync.RWMutex
 	Util
 }
 
+//inter statistics, atomic opt
+type connStats struct {
+	connectTime     int64 //unix nano
+	readMessages    int64
+	readBytes       int64
+	writeMessages   int64
+	writeBytes      int64
+	droppedMessages int64
+	rateLimited     int64
+	tooBigMessages  int64
+	lastReadTime    int64 //unix nano
+	lastWriteTime   int64 //unix nano
+	lastPingRtt     int64 //nano seconds
+	closeReason     atomic.Value //*gvar.CloseReason
+}
+
 type interWriteData struct {
 	data 		[]byte
 	directWrite bool
+	object      interface{} //data of ordered write
+	messageType int
+	result      chan error //result of ordered write, nil for queued write
 }
 
 //construct
@@ -85,20 +151,62 @@ func NewConnector(
 
 //close
 func (f *Connector) Close() {
+	f.setCloseReason(&gvar.CloseReason{
+		Code:      define.CloseCodeNormal,
+		Reason:    "closed by server",
+		Initiator: gvar.CloseByServer,
+	})
 	f.closeOnce.Do(func() {
+		if f.conf.Wheel != nil {
+			f.conf.Wheel.Remove(f.connId)
+		}
+		if f.rpcPending != nil {
+			f.rpcPending.Quit()
+		}
+		if f.eventAcks != nil {
+			f.eventAcks.Quit()
+		}
 		close(f.messageCloseChan)
 		f.connLocker.Lock()
 		defer f.connLocker.Unlock()
 		if f.conn != nil {
 			close(f.closeChan)
-			f.conn.Close()
+			if atomic.LoadInt32(&f.closeSent) > 0 {
+				//close frame sent with code
+				CloseTransport(f.conn)
+			}else{
+				f.conn.Close()
+			}
 			f.conn = nil
 		}
 	})
 }
 
+//get done chan, closed when connect closed
+func (f *Connector) Done() <-chan bool {
+	return f.closeChan
+}
+
+//call client method and wait response
+func (f *Connector) Call(ctx context.Context, method string, payload interface{}) (json.RawMessage, error) {
+	if f.rpcPending == nil {
+		return nil, ErrRpcNotEnabled
+	}
+	return f.rpcPending.Call(ctx, method, payload, f.writeRpc)
+}
+
+//emit named event to client
+//ack callback is optional, need event enabled
+func (f *Connector) Emit(event string, data interface{}, acks ...func(data json.RawMessage)) error {
+	msg, err := GenEventMsg(event, data, f.eventAcks, acks...)
+	if err != nil {
+		return err
+	}
+	return f.writeEvent(msg)
+}
+
 //set config id
-func (f *Connector) SetConfId(bucketId int, groupId int64) {
+func (f *Connector) SetConfId(bucketId int, groupId string) {
 	f.conf.BucketId = bucketId
 	f.conf.GroupId = groupId
 }
@@ -108,14 +216,58 @@ func (f *Connector) GetActiveTime() int64 {
 	return f.activeTime
 }
 
+//get statistics snapshot
+func (f *Connector) Stats() gvar.ConnStats {
+	stats := gvar.ConnStats{
+		ConnId:          f.connId,
+		OwnerId:         f.GetOwnerId(),
+		ConnectTime:     time.Unix(0, atomic.LoadInt64(&f.stats.connectTime)),
+		ReadMessages:    atomic.LoadInt64(&f.stats.readMessages),
+		ReadBytes:       atomic.LoadInt64(&f.stats.readBytes),
+		WriteMessages:   atomic.LoadInt64(&f.stats.writeMessages),
+		WriteBytes:      atomic.LoadInt64(&f.stats.writeBytes),
+		DroppedMessages: atomic.LoadInt64(&f.stats.droppedMessages),
+		RateLimited:     atomic.LoadInt64(&f.stats.rateLimited),
+		TooBigMessages:  atomic.LoadInt64(&f.stats.tooBigMessages),
+		WriteQueueDepth: len(f.writeChan),
+		ReadQueueDepth:  len(f.messageChan),
+		LastPingRtt:     time.Duration(atomic.LoadInt64(&f.stats.lastPingRtt)),
+	}
+	if ts := atomic.LoadInt64(&f.stats.lastReadTime); ts > 0 {
+		stats.LastReadTime = time.Unix(0, ts)
+	}
+	if ts := atomic.LoadInt64(&f.stats.lastWriteTime); ts > 0 {
+		stats.LastWriteTime = time.Unix(0, ts)
+	}
+	stats.CloseReason = f.GetCloseReason()
+	return stats
+}
+
+//get close reason, nil means still opening
+func (f *Connector) GetCloseReason() *gvar.CloseReason {
+	reason, _ := f.stats.closeReason.Load().(*gvar.CloseReason)
+	return reason
+}
+
+//update last ping rtt
+//measured by ping frame if `PingInterval` set, or fed by heartbeat of application level
+func (f *Connector) UpdatePingRtt(rtt time.Duration) {
+	atomic.StoreInt64(&f.stats.lastPingRtt, int64(rtt))
+}
+
+//get remote ip
+func (f *Connector) GetRemoteIp() string {
+	return f.remoteIp
+}
+
 //get owner id
 func (f *Connector) GetOwnerId() int64 {
-	return f.ownerId
+	return atomic.LoadInt64(&f.ownerId)
 }
 
 //set owner id
 func (f *Connector) SetOwnerId(ownerId int64) {
-	f.ownerId = ownerId
+	atomic.StoreInt64(&f.ownerId, ownerId)
 }
 
 //remove property
@@ -162,28 +314,74 @@ func (f *Connector) SetProp(kind string, val interface{}) error {
 	return nil
 }
 
-//close with message
-func (f *Connector) CloseWithMessage(message string) error {
+//get copy of all properties
+func (f *Connector) GetProps() map[string]interface{} {
+	f.propLocker.RLock()
+	defer f.propLocker.RUnlock()
+	result := make(map[string]interface{}, len(f.propertyMap))
+	for k, v := range f.propertyMap {
+		result[k] = v
+	}
+	return result
+}
+
+//park closed connector, keep written messages for resumed connect
+func (f *Connector) Park(maxMessages int) {
+	f.connLocker.Lock()
+	defer f.connLocker.Unlock()
+	if f.missed == nil {
+		f.missed = newMissedQueue(maxMessages)
+	}
+}
+
+//take kept messages and stop keeping
+func (f *Connector) TakeMissed() []*gvar.MissedMsg {
+	f.connLocker.Lock()
+	missed := f.missed
+	f.missed = nil
+	f.connLocker.Unlock()
+	if missed == nil {
+		return nil
+	}
+	return missed.take()
+}
+
+//close with status code and reason
+//send close frame before close it
+func (f *Connector) CloseWithCode(code int, reason string) error {
 	//check
-	if message == "" {
+	if !IsSendableCloseCode(code) {
 		return errors.New("invalid parameter")
 	}
-	if f.conn == nil {
+	reason = TruncateCloseReason(reason)
+
+	//get connector reference
+	f.connLocker.Lock()
+	conn := f.conn
+	if conn == nil {
+		f.connLocker.Unlock()
 		return errors.New("connect has closed")
 	}
+	conn.SetWriteDeadline(time.Now().Add(f.writeTimeout))
+	f.connLocker.Unlock()
 
-	//write message before close it
-	f.closeOnce.Do(func() {
-		close(f.messageCloseChan)
-		f.connLocker.Lock()
-		defer f.connLocker.Unlock()
-		if f.conn != nil {
-			f.conn.Write([]byte(message))
-			f.conn.Close()
-			f.conn = nil
-		}
+	//send close frame
+	f.setCloseReason(&gvar.CloseReason{
+		Code:      code,
+		Reason:    reason,
+		Initiator: gvar.CloseByServer,
 	})
-	return nil
+	atomic.StoreInt32(&f.closeSent, 1)
+	err := f.SendCloseFrame(conn, code, reason)
+
+	//close origin connect
+	f.Close()
+	return err
+}
+
+//get message type of router or group config
+func (f *Connector) GetMessageType() int {
+	return f.conf.MessageType
 }
 
 //get origin connect id
@@ -228,11 +426,17 @@ func (f *Connector) QueueWrite(data []byte, directWrites ...bool) error {
 		directWrite = directWrites[0]
 	}
 
+	//keep message if parked
+	if f.keepMissed(&gvar.MissedMsg{Data: data, InQueue: true, DirectWrite: directWrite}) {
+		return nil
+	}
+
 	isClosed, err := f.IsChanClosed(f.writeChan)
 	if err != nil {
 		return err
 	}
 	if isClosed {
+		atomic.AddInt64(&f.stats.droppedMessages, 1)
 		return fmt.Errorf("connect %v write chan is closed", f.connId)
 	}
 
@@ -245,23 +449,73 @@ func (f *Connector) QueueWrite(data []byte, directWrites ...bool) error {
 	return nil
 }
 
-//send message with timeout
+//send message with timeout, default octet message type
+//ordered mode, pass write queue and wait until sent
 func (f *Connector) Write(data interface{}, messageTypes ...int) error {
 	var (
 		messageType int
-		err error
 	)
 	//check
 	if data == nil {
 		return errors.New("invalid parameter")
 	}
-	if f.conn == nil {
-		return errors.New("connect is nil")
-	}
 	if messageTypes != nil && len(messageTypes) > 0 {
 		messageType = messageTypes[0]
 	}
 
+	//keep message if parked
+	if f.keepMissed(&gvar.MissedMsg{Data: data, MessageType: messageType}) {
+		return nil
+	}
+	if f.conf.OrderedWrite {
+		return f.orderedWrite(data, messageType)
+	}
+	return f.write(data, messageType)
+}
+
+//push to write queue and wait result
+func (f *Connector) orderedWrite(data interface{}, messageType int) error {
+	isClosed, err := f.IsChanClosed(f.writeChan)
+	if err != nil {
+		return err
+	}
+	if isClosed {
+		atomic.AddInt64(&f.stats.droppedMessages, 1)
+		return fmt.Errorf("connect %v write chan is closed", f.connId)
+	}
+
+	//write to chan and wait
+	iwd := interWriteData{
+		object:      data,
+		messageType: messageType,
+		result:      make(chan error, 1),
+	}
+	select {
+	case f.writeChan <- iwd:
+	case <-f.closeChan:
+		return errors.New("connect is closed")
+	}
+	select {
+	case err = <-iwd.result:
+		return err
+	case <-f.closeChan:
+		return errors.New("connect is closed")
+	}
+}
+
+//send message with timeout directly
+func (f *Connector) write(data interface{}, messageType int) error {
+	var (
+		err error
+	)
+	//keep message if parked
+	if f.keepMissed(&gvar.MissedMsg{Data: data, MessageType: messageType}) {
+		return nil
+	}
+	if f.conn == nil {
+		return errors.New("connect is nil")
+	}
+
 	//update active time
 	defer func() {
 		f.updateActiveTime(time.Now().Unix())
@@ -281,8 +535,14 @@ func (f *Connector) Write(data interface{}, messageTypes ...int) error {
 	switch messageType {
 	case gvar.MessageTypeOfJson:
 		{
-			//json format
-			err = websocket.JSON.Send(conn, data)
+			//json format, same as websocket.JSON codec
+			var jsonData []byte
+			jsonData, err = json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			err = websocket.Message.Send(conn, string(jsonData))
+			data = jsonData
 		}
 	case gvar.MessageTypeOfOctet:
 		fallthrough
@@ -292,6 +552,9 @@ func (f *Connector) Write(data interface{}, messageTypes ...int) error {
 			err = websocket.Message.Send(conn, data)
 		}
 	}
+	if err == nil {
+		f.statWrite(data)
+	}
 	return err
 }
 
@@ -337,22 +600,32 @@ func (f *Connector) Read(messageTypes ...int) (interface{}, error) {
 	f.connLocker.RUnlock()
 
 	//receive data
+	var byteData []byte
+	err = websocket.Message.Receive(conn, &byteData)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&f.stats.readMessages, 1)
+	atomic.AddInt64(&f.stats.readBytes, int64(len(byteData)))
+	atomic.StoreInt64(&f.stats.lastReadTime, time.Now().UnixNano())
+
 	switch messageType {
 	case gvar.MessageTypeOfJson:
 		{
-			//json format
+			//json format, same as websocket.JSON codec
 			var data interface{}
-			err = websocket.JSON.Receive(conn, &data)
-			return data, err
+			err = json.Unmarshal(byteData, &data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", errInvalidMessage, err)
+			}
+			return data, nil
 		}
 	case gvar.MessageTypeOfOctet:
 		fallthrough
 	default:
 		{
 			//general octet format
-			var data []byte
-			err = websocket.Message.Receive(conn, &data)
-			return data, err
+			return byteData, nil
 		}
 	}
 }
@@ -369,6 +642,173 @@ func (f *Connector) updateActiveTime(ts int64) {
 	f.activeTime = ts
 }
 
+//update write statistics
+func (f *Connector) statWrite(data interface{}) {
+	var size int
+	switch v := data.(type) {
+	case []byte:
+		size = len(v)
+	case string:
+		size = len(v)
+	}
+	atomic.AddInt64(&f.stats.writeMessages, 1)
+	atomic.AddInt64(&f.stats.writeBytes, int64(size))
+	atomic.StoreInt64(&f.stats.lastWriteTime, time.Now().UnixNano())
+}
+
+//check and handle rpc message
+//return true if it's rpc message
+func (f *Connector) handleRpc(data interface{}) bool {
+	if f.conf.Rpc == nil {
+		return false
+	}
+	msg := ParseRpcMsg(data)
+	if msg == nil {
+		return false
+	}
+	if msg.Rpc == define.RpcOfResponse {
+		f.rpcPending.Resolve(msg)
+		return true
+	}
+
+	//serve request in new goroutine, handler may call client too
+	go func() {
+		resp := f.conf.Rpc.Serve(f, msg)
+		if err := f.writeRpc(resp); err != nil {
+			log.Printf("connect %v write rpc response failed, err:%v\n", f.connId, err)
+		}
+	}()
+	return true
+}
+
+//check and handle json rpc 2.0 message
+//return true if it's json rpc request or notification
+func (f *Connector) handleJsonRpc(data interface{}) bool {
+	var (
+		byteData []byte
+	)
+	if f.conf.JsonRpc == nil {
+		return false
+	}
+	switch v := data.(type) {
+	case []byte:
+		byteData = v
+	case string:
+		byteData = []byte(v)
+	default:
+		//decoded by json message type
+		byteData, _ = json.Marshal(v)
+	}
+
+	//check response of client
+	resp, handled := f.conf.JsonRpc.Serve(f, byteData)
+	if !handled {
+		return false
+	}
+	if resp == nil {
+		return true
+	}
+	if err := f.Write(json.RawMessage(resp), gvar.MessageTypeOfJson); err != nil {
+		log.Printf("connect %v write json rpc response failed, err:%v\n", f.connId, err)
+	}
+	return true
+}
+
+//check and handle event message
+//return true if it's ack or handled event
+func (f *Connector) handleEvent(data interface{}) bool {
+	if f.conf.Event == nil {
+		return false
+	}
+	msg := ParseEventMsg(data)
+	if msg == nil {
+		return false
+	}
+	if msg.Event == "" {
+		f.eventAcks.Resolve(msg)
+		return true
+	}
+	return f.conf.Event.Dispatch(f, msg, f.writeEvent)
+}
+
+//check and handle topic sub or unsub control message
+func (f *Connector) handlePubSub(data interface{}) bool {
+	if f.conf.PubSub == nil {
+		return false
+	}
+	return f.conf.PubSub.HandleControl(f, data)
+}
+
+//check and handle reliable message ack
+func (f *Connector) handleReliable(data interface{}) bool {
+	if f.conf.Reliable == nil {
+		return false
+	}
+	msg := ParseReliableMsg(data)
+	if msg == nil || msg.Rel != define.ReliableOfAck {
+		return false
+	}
+	f.conf.Reliable.Ack(f.GetOwnerId(), msg.Seq)
+	return true
+}
+
+//write event message as json text
+func (f *Connector) writeEvent(msg *gvar.EventMsg) error {
+	return f.Write(msg, gvar.MessageTypeOfJson)
+}
+
+//write rpc message as json text
+func (f *Connector) writeRpc(msg *gvar.RpcMsg) error {
+	return f.Write(msg, gvar.MessageTypeOfJson)
+}
+
+//keep message of parked connector
+//return false if not parked
+func (f *Connector) keepMissed(msg *gvar.MissedMsg) bool {
+	f.connLocker.RLock()
+	missed := f.missed
+	f.connLocker.RUnlock()
+	if missed == nil {
+		return false
+	}
+	if !msg.InQueue && msg.MessageType == gvar.MessageTypeOfJson {
+		//encode json now, data may be changed before replay
+		if byteData, err := json.Marshal(msg.Data); err == nil {
+			msg.Data = json.RawMessage(byteData)
+		}
+	}
+	missed.add(msg)
+	return true
+}
+
+//gen close reason of read eof by close tap
+//without tap, close frame and connect lost can't be told apart
+func (f *Connector) genEofCloseReason(err error) *gvar.CloseReason {
+	if f.tap != nil {
+		return f.tap.GenCloseReason(err)
+	}
+	return &gvar.CloseReason{
+		Code:      define.CloseCodeNoStatus,
+		Reason:    "closed by client or lost, status unknown",
+		Initiator: gvar.CloseByClient,
+		Err:       err,
+	}
+}
+
+//reject message over max size or frames, and close connect
+func (f *Connector) rejectTooBig() {
+	atomic.AddInt64(&f.stats.tooBigMessages, 1)
+	if f.conf.CBForTooBig != nil {
+		f.conf.CBForTooBig(f.GetConnId())
+	}
+	f.CloseWithCode(define.CloseCodeMessageTooBig, "message too big")
+}
+
+//set close reason, only the first reason kept
+func (f *Connector) setCloseReason(reason *gvar.CloseReason) {
+	f.stats.closeReason.CompareAndSwap(nil, reason)
+}
+
 //write pure data
 func (f *Connector) writePureData(data []byte) error {
 	//check
@@ -380,6 +820,7 @@ func (f *Connector) writePureData(data []byte) error {
 	f.connLocker.Lock()
 	if f.conn == nil {
 		//conn has closed
+		f.connLocker.Unlock()
 		return errors.New("conn is nil")
 	}
 	//setup write deadline
@@ -392,14 +833,22 @@ func (f *Connector) writePureData(data []byte) error {
 	if err != nil {
 		if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
 			//connect closed
+			f.setCloseReason(&gvar.CloseReason{
+				Code:      define.CloseCodeAbnormal,
+				Reason:    "write failed",
+				Initiator: gvar.CloseByNetwork,
+				Err:       err,
+			})
 			if f.conf != nil && f.conf.CBForClosed != nil {
 				f.conf.CBForClosed(f.connId)
 			}
 			return err
 		}
 		log.Printf("connect %v write error: %v", f.connId, err)
+		return err
 	}
-	return err
+	f.statWrite(data)
+	return nil
 }
 
 //write process
@@ -419,10 +868,12 @@ func (f *Connector) writeProcess() {
 		case iwd, isOk = <- f.writeChan:
 			{
 				if isOk && &iwd != nil {
-					if iwd.directWrite {
+					if iwd.result != nil {
+						iwd.result <- f.write(iwd.object, iwd.messageType)
+					}else if iwd.directWrite {
 						f.writePureData(iwd.data)
 					}else{
-						f.Write(iwd.data, f.conf.MessageType)
+						f.write(iwd.data, f.conf.MessageType)
 					}
 				}
 			}
@@ -434,6 +885,42 @@ func (f *Connector) writeProcess() {
 	}
 }
 
+//ping process, send ping frame with send time
+func (f *Connector) pingProcess() {
+	ticker := time.NewTicker(f.conf.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.connLocker.RLock()
+			conn := f.conn
+			f.connLocker.RUnlock()
+			if conn == nil {
+				return
+			}
+			payload := make([]byte, 8)
+			binary.BigEndian.PutUint64(payload, uint64(time.Now().UnixNano()))
+			if err := pingFrameCodec.Send(conn, payload); err != nil {
+				//failed connect closed by read process
+				return
+			}
+		case <-f.closeChan:
+			return
+		}
+	}
+}
+
+//measure ping rtt by pong payload
+func (f *Connector) onPong(payload []byte) {
+	if len(payload) != 8 {
+		return
+	}
+	sendTime := int64(binary.BigEndian.Uint64(payload))
+	if rtt := time.Now().UnixNano() - sendTime; rtt >= 0 {
+		f.UpdatePingRtt(time.Duration(rtt))
+	}
+}
+
 //read process
 func (f *Connector) readProcess() {
 	var (
@@ -443,6 +930,12 @@ func (f *Connector) readProcess() {
 		if pErr := recover(); pErr != m {
 			log.Printf("connect %v read process panic, err:%v\n", f.connId, pErr)
 		}
+		//notify closed and release connect
+		if f.conf.CBForClosed != nil {
+			f.conf.CBForClosed(f.GetConnId())
+		}
+		f.Close()
+
 		//close message chan
 		close(f.messageChan)
 	}()
@@ -453,21 +946,48 @@ func (f *Connector) readProcess() {
 	//read process loop
 	for {
 		if !f.isConnected() {
-			continue
+			//closed by server side
+			break
 		}
 		data, err := f.Read(f.conf.MessageType)
 		if err != nil {
-			if err == io.EOF {
-				if f.conf.CBForClosed != nil {
-					f.conf.CBForClosed(f.GetConnId())
-				}
-				break
-			}
 			if netErr, sok := err.(net.Error); sok && netErr.Timeout() {
 				//read timeout, but continue
 				continue
 			}
-			//other error, still continue
+			if errors.Is(err, errInvalidMessage) {
+				//bad message, skip it
+				f.readFrames++
+				continue
+			}
+			if errors.Is(err, websocket.ErrFrameTooLarge) {
+				//message over max size, reject before buffered
+				f.rejectTooBig()
+				break
+			}
+			if err == io.EOF {
+				//client close or lost
+				f.setCloseReason(f.genEofCloseReason(err))
+			}else{
+				f.setCloseReason(&gvar.CloseReason{
+					Code:      define.CloseCodeAbnormal,
+					Reason:    "read failed",
+					Initiator: gvar.CloseByNetwork,
+					Err:       err,
+				})
+			}
+			break
+		}
+
+		//check fragmented message over max size or frames
+		f.readFrames++
+		if f.tap != nil && f.tap.IsTooBig(f.readFrames) {
+			f.rejectTooBig()
+			break
+		}
+
+		//check inbound rate limit
+		if !f.checkRateLimit() {
 			continue
 		}
 
@@ -476,11 +996,77 @@ func (f *Connector) readProcess() {
 		case f.messageChan <- data:
 		default:
 			//queue full
+			atomic.AddInt64(&f.stats.droppedMessages, 1)
 			log.Printf("connector %v message queue full, dropping message", f.connId)
 		}
 	}
 }
 
+//check idle or not, close idle connect
+//run by timing wheel
+func (f *Connector) checkIdle() {
+	if !f.isConnected() {
+		return
+	}
+
+	//get last active time
+	lastTime := atomic.LoadInt64(&f.stats.lastReadTime)
+	if f.conf.IdleMode == gvar.IdleModeOfReadWrite {
+		lastWriteTime := atomic.LoadInt64(&f.stats.lastWriteTime)
+		if lastWriteTime > lastTime {
+			lastTime = lastWriteTime
+		}
+	}
+	if lastTime <= 0 {
+		lastTime = atomic.LoadInt64(&f.stats.connectTime)
+	}
+
+	//check idle duration
+	idleDuration := time.Duration(time.Now().UnixNano() - lastTime)
+	if idleDuration < f.conf.IdleTimeout {
+		//still active, check again later
+		f.conf.Wheel.Add(f.connId, f.conf.IdleTimeout-idleDuration, f.checkIdle)
+		return
+	}
+	f.CloseWithCode(define.CloseCodeGoingAway, "idle timeout")
+}
+
+//check inbound rate limit
+//return true if message passed
+func (f *Connector) checkRateLimit() bool {
+	if f.conf.Limiter == nil {
+		return true
+	}
+	kind, policy, wait := f.conf.Limiter.Check(f.connBucket, f.GetOwnerId(), f.remoteIp)
+	if kind < 0 {
+		return true
+	}
+
+	//limit triggered
+	atomic.AddInt64(&f.stats.rateLimited, 1)
+	if f.conf.CBForLimited != nil {
+		f.conf.CBForLimited(f.connId, kind)
+	}
+	switch policy {
+	case gvar.RateLimitPolicyOfDelay:
+		{
+			//pause reading, apply backpressure to client
+			time.Sleep(wait)
+			return true
+		}
+	case gvar.RateLimitPolicyOfDisconnect:
+		{
+			f.CloseWithCode(define.CloseCodePolicyViolation, "rate limit exceeded")
+			return false
+		}
+	default:
+		{
+			atomic.AddInt64(&f.stats.droppedMessages, 1)
+			return false
+		}
+	}
+}
+
 //async message worker
 func (f *Connector) asyncMessageWorker() {
 	var(
@@ -499,6 +1085,9 @@ func (f *Connector) asyncMessageWorker() {
 		select {
 		case data, isOk = <- f.messageChan:
 			if isOk && &data != nil {
+				if f.handleRpc(data) || f.handleJsonRpc(data) || f.handleEvent(data) || f.handlePubSub(data) || f.handleReliable(data) {
+					break
+				}
 				if f.conf != nil && f.conf.CBForRead != nil {
 					//unblock read data
 					func(connId int64, messageType int, data interface{}) {
@@ -541,6 +1130,29 @@ func (f *Connector) interInit(timeouts ...time.Duration) {
 		f.asyncWorkerNum = f.conf.AsyncWorkerNum
 	}
 
+	//setup remote ip and rate limit
+	f.remoteIp = f.Util.GetRemoteIp(f.conn)
+	if f.conf.Limiter != nil {
+		f.connBucket = f.conf.Limiter.NewConnBucket()
+	}
+
+	//setup rpc pending calls and event acks
+	if f.conf.Rpc != nil {
+		f.rpcPending = NewRpcPending()
+	}
+	if f.conf.Event != nil {
+		f.eventAcks = NewEventAcks()
+	}
+
+	//setup max message size and frames
+	if f.conf.MaxMessageSize > 0 && f.conn != nil {
+		f.conn.MaxPayloadBytes = f.conf.MaxMessageSize
+	}
+	f.tap = GetCloseTap(f.conn)
+	if f.tap != nil {
+		f.tap.SetMessageLimit(f.conf.MaxMessageSize, f.conf.MaxFramesPerMessage)
+	}
+
 	//init deadline
 	f.deadlineLocker.Lock()
 	f.readDeadline = time.Now().Add(f.readTimeout)
@@ -549,10 +1161,22 @@ func (f *Connector) interInit(timeouts ...time.Duration) {
 
 	//update active time
 	f.updateActiveTime(time.Now().Unix())
+	atomic.StoreInt64(&f.stats.connectTime, time.Now().UnixNano())
+
+	//setup idle check
+	if f.conf.Wheel != nil && f.conf.IdleTimeout > 0 {
+		f.conf.Wheel.Add(f.connId, f.conf.IdleTimeout, f.checkIdle)
+	}
 
 	//run write process
 	go f.writeProcess()
 
 	//run read process
 	go f.readProcess()
+
+	//run ping process, pong seen by tap only
+	if f.tap != nil && f.conf.PingInterval > 0 {
+		f.tap.SetPongHandler(f.onPong)
+		go f.pingProcess()
+	}
 }
\ No newline at end of file
@@ -1,10 +1,12 @@
 package face
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"runtime"
 	"strconv"
 	"sync"
@@ -27,30 +29,56 @@ import (
 type Dynamic struct {
 	cfg          *gvar.GroupConf        //router origin conf reference
 	connId       int64                  //inter atomic conn id counter
-	groupMap     map[int64]iface.IGroup //dynamic group map
+	groupKey     int64                  //inter atomic group key counter
+	groupMap     map[string]iface.IGroup //dynamic group map
+	connMap      map[int64]iface.IConnector //connects of multi groups mode
+	shared       *Shared                //shared components of groups
+	admission    *Admission             //connect admission
+	groupWheel   *TimingWheel           //empty group reclaim timing wheel
 	sync.RWMutex
 	Util
 }
 
 //construct
-func NewDynamic(cfg *gvar.GroupConf) *Dynamic {
+func NewDynamic(cfg *gvar.GroupConf, registry *OwnerRegistry, pubSub *PubSub, reliable *Reliable) *Dynamic {
 	this := &Dynamic{
 		cfg: cfg,
-		groupMap: map[int64]iface.IGroup{},
+		groupMap: map[string]iface.IGroup{},
+		connMap: map[int64]iface.IConnector{},
 	}
-	this.interInit()
+	this.interInit(registry, pubSub, reliable)
 	return this
 }
 
 //quit
 func (f *Dynamic) Quit() {
+	//release old maps with locker
 	f.Lock()
-	defer f.Unlock()
-	for _, v := range f.groupMap {
+	groupMap := f.groupMap
+	connMap := f.connMap
+	f.groupMap = nil
+	f.connMap = nil
+	f.Unlock()
+
+	for _, v := range groupMap {
 		v.Quit()
 	}
-	//release old group map
-	f.groupMap = nil
+	if f.groupWheel != nil {
+		f.groupWheel.Quit()
+	}
+
+	//force close connects of multi groups mode and notify outside
+	for connId, v := range connMap {
+		if v == nil {
+			continue
+		}
+		v.CloseWithCode(define.CloseCodeGoingAway, "server shutdown")
+		f.shared.ReleaseConn(v)
+		if f.cfg.CBForClosed != nil {
+			f.cfg.CBForClosed(f, "", connId, v.GetCloseReason())
+		}
+	}
+	f.shared.Quit()
 }
 
 //get conf
@@ -59,9 +87,9 @@ func (f *Dynamic) GetConf() *gvar.GroupConf {
 }
 
 //remove group by id
-func (f *Dynamic) RemoveGroup(groupId int64) error {
+func (f *Dynamic) RemoveGroup(groupId string) error {
 	//check
-	if groupId <= 0 {
+	if groupId == "" {
 		return errors.New("invalid parameter")
 	}
 
@@ -71,10 +99,22 @@ func (f *Dynamic) RemoveGroup(groupId int64) error {
 		return errors.New("no such group")
 	}
 	oldGroup.Quit()
+	f.removeHistory(groupId)
+	if f.groupWheel != nil {
+		if v, ok := oldGroup.(*Group); ok {
+			f.groupWheel.Remove(v.key)
+		}
+	}
 
 	//remove with locker
 	f.Lock()
-	defer f.Unlock()
+	defer func() {
+		f.Unlock()
+		//check and call the removed cb of outside
+		if f.cfg.CBForGroupRemoved != nil {
+			f.cfg.CBForGroupRemoved(oldGroup, groupId)
+		}
+	}()
 	delete(f.groupMap, groupId)
 
 	//hit gc rate
@@ -93,9 +133,9 @@ func (f *Dynamic) RemoveGroup(groupId int64) error {
 }
 
 //get group by id
-func (f *Dynamic) GetGroup(groupId int64) (iface.IGroup, error) {
+func (f *Dynamic) GetGroup(groupId string) (iface.IGroup, error) {
 	//check
-	if groupId <= 0 {
+	if groupId == "" {
 		return nil, errors.New("invalid parameter")
 	}
 	if f.groupMap == nil {
@@ -114,32 +154,27 @@ func (f *Dynamic) GetGroup(groupId int64) (iface.IGroup, error) {
 
 //create new group
 //the new group should be pre-create
-func (f *Dynamic) CreateGroup(groupId int64) (iface.IGroup, error) {
+func (f *Dynamic) CreateGroup(groupId string) (iface.IGroup, error) {
 	//check
-	if groupId <= 0 {
+	if groupId == "" {
 		return nil, errors.New("invalid parameter")
 	}
 
-	//get group first
-	oldGroup, _ := f.GetGroup(groupId)
-	if oldGroup != nil {
+	//get or create new
+	newGroup, isNew, err := f.loadOrCreateGroup(groupId)
+	if err != nil {
+		return nil, err
+	}
+	if !isNew {
 		return nil, errors.New("group had created")
 	}
-
-	//create new
-	newGroup := NewGroup(groupId, f.cfg)
-
-	//sync into env with locker
-	f.Lock()
-	defer f.Unlock()
-	f.groupMap[groupId] = newGroup
 	return newGroup, nil
 }
 
 //cast message
-func (f *Dynamic) Cast(groupId int64, msg *gvar.MsgData) error {
+func (f *Dynamic) Cast(groupId string, msg *gvar.MsgData) error {
 	//check
-	if groupId <= 0 || msg == nil || msg.Data == nil {
+	if groupId == "" || msg == nil || msg.Data == nil {
 		return errors.New("invalid parameter")
 	}
 
@@ -154,6 +189,195 @@ func (f *Dynamic) Cast(groupId int64, msg *gvar.MsgData) error {
 	return err
 }
 
+//cast message and wait delivery report
+func (f *Dynamic) CastWithReport(groupId string, msg *gvar.MsgData) (*gvar.DeliveryReport, error) {
+	//get target group
+	targetGroup, err := f.GetGroup(groupId)
+	if err != nil || targetGroup == nil {
+		return nil, err
+	}
+	return targetGroup.CastWithReport(msg)
+}
+
+//remove group by int64 id
+func (f *Dynamic) RemoveGroupInt(groupId int64) error {
+	return f.RemoveGroup(strconv.FormatInt(groupId, 10))
+}
+
+//get group by int64 id
+func (f *Dynamic) GetGroupInt(groupId int64) (iface.IGroup, error) {
+	return f.GetGroup(strconv.FormatInt(groupId, 10))
+}
+
+//create new group by int64 id
+func (f *Dynamic) CreateGroupInt(groupId int64) (iface.IGroup, error) {
+	return f.CreateGroup(strconv.FormatInt(groupId, 10))
+}
+
+//cast message by int64 group id
+func (f *Dynamic) CastInt(groupId int64, msg *gvar.MsgData) error {
+	return f.Cast(strconv.FormatInt(groupId, 10), msg)
+}
+
+//get connect of multi groups mode
+func (f *Dynamic) GetConn(connId int64) (iface.IConnector, error) {
+	//check
+	if connId <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//get with locker
+	f.RLock()
+	defer f.RUnlock()
+	v, ok := f.connMap[connId]
+	if !ok || v == nil {
+		return nil, errors.New("no such connector")
+	}
+	return v, nil
+}
+
+//set owner of connect in multi groups mode
+func (f *Dynamic) SetOwner(connId, ownerId int64) error {
+	//check
+	if connId <= 0 || ownerId <= 0 {
+		return errors.New("invalid parameter")
+	}
+	connector, err := f.GetConn(connId)
+	if err != nil {
+		return err
+	}
+
+	//check owner policy with connects of dynamic,
+	//and set owner in all joined groups with owner locker
+	unlock := f.shared.owners.lock(ownerId)
+	kicks, err := checkOwnerPolicy(f.cfg.OwnerPolicy, connId, f.getConnsByOwner(ownerId))
+	if err != nil {
+		unlock()
+		connector.CloseWithCode(define.CloseCodePolicyViolation, err.Error())
+		return err
+	}
+	oldOwnerId := connector.GetOwnerId()
+	for _, groupId := range f.GetGroupsOfConn(connId) {
+		groupObj, _ := f.GetGroup(groupId)
+		if group, ok := groupObj.(*Group); ok {
+			group.setOwnerIndex(connector, ownerId)
+		}
+	}
+	connector.SetOwnerId(ownerId)
+	unlock()
+
+	//kick and bind owner once without locker, it may write to connector
+	kickOwnerConns(kicks)
+	f.shared.BindOwner(oldOwnerId, ownerId, connector)
+	return nil
+}
+
+//get connects of owner in multi groups mode
+func (f *Dynamic) getConnsByOwner(ownerId int64) []iface.IConnector {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]iface.IConnector, 0)
+	for _, v := range f.connMap {
+		if v != nil && v.GetOwnerId() == ownerId {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+//join connect into group, only for multi groups mode
+//replay default history after joined
+func (f *Dynamic) JoinGroup(connId int64, groupId string) error {
+	groupObj, err := f.joinGroup(connId, groupId)
+	if err != nil {
+		return err
+	}
+	return groupObj.Replay(connId, nil)
+}
+
+//leave connect from group, only for multi groups mode
+func (f *Dynamic) LeaveGroup(connId int64, groupId string) error {
+	//check
+	if connId <= 0 || groupId == "" {
+		return errors.New("invalid parameter")
+	}
+
+	//get group
+	groupObj, err := f.GetGroup(groupId)
+	if err != nil {
+		return err
+	}
+	return groupObj.Leave(connId)
+}
+
+//get joined group ids of connect
+func (f *Dynamic) GetGroupsOfConn(connId int64) []string {
+	if f.shared.Members == nil {
+		return nil
+	}
+	return f.shared.Members.Get(connId)
+}
+
+//register rpc method handler for all groups
+func (f *Dynamic) RegisterRpc(method string, handler gvar.RpcHandler) error {
+	return f.shared.RegisterRpc(method, handler)
+}
+
+//register json rpc 2.0 method handler for all groups
+func (f *Dynamic) RegisterJsonRpc(method string, handler interface{}) error {
+	return f.shared.RegisterJsonRpc(method, handler)
+}
+
+//send json rpc 2.0 notification to group
+//target used for owner, connect and filter, nil means all connects
+func (f *Dynamic) Notify(groupId string, method string, params interface{}, target *gvar.MsgData) error {
+	data, err := GenJsonRpcNotify(f.cfg.MessageType, method, params)
+	if err != nil {
+		return err
+	}
+	msg := &gvar.MsgData{}
+	if target != nil {
+		*msg = *target
+	}
+	msg.Data = data
+	msg.WriteInQueue = false
+	return f.Cast(groupId, msg)
+}
+
+//register named event handler for all groups
+func (f *Dynamic) On(event string, handler gvar.EventHandler) error {
+	return f.shared.On(event, handler)
+}
+
+//set group history store, like file backed history
+//should be called before any cast, old store will quit
+func (f *Dynamic) SetHistoryStore(store iface.IHistoryStore) {
+	old := f.shared.History
+	f.shared.History = store
+	if old != nil && old != store {
+		old.Quit()
+	}
+}
+
+//set owner offline message store, like file backed wal
+//should be called before any cast, old store will quit
+func (f *Dynamic) SetOfflineStore(store iface.IOfflineStore) {
+	old := f.shared.Offline
+	f.shared.Offline = store
+	if old != nil && old != store {
+		old.Quit()
+	}
+}
+
+//check connect admission before handshake
+//return release func if admitted
+func (f *Dynamic) Admit(req *http.Request) (func(), error) {
+	if f.admission == nil {
+		return func() {}, nil
+	}
+	return f.admission.Acquire(req)
+}
+
 //websocket request entry
 func (f *Dynamic) Entry(conn *websocket.Conn) {
 	var (
@@ -164,50 +388,481 @@ func (f *Dynamic) Entry(conn *websocket.Conn) {
 		return
 	}
 
+	//resume parked session by token
+	if connector := f.resumeSession(conn); connector != nil {
+		<-connector.Done()
+		return
+	}
+
+	//multi groups mode
+	if f.cfg.MultiGroup {
+		f.multiEntry(conn)
+		return
+	}
+
 	//check group id para
 	groupId, err := f.getAndVerifyGroupId(conn)
-	if err != nil || groupId <= 0 {
+	if err != nil || groupId == "" {
 		log.Printf("group %v, verify group id failed", groupId)
 		return
 	}
 
 	//gen new connect id
-	if f.cfg.CBForGenConnId != nil {
-		newConnId = f.cfg.CBForGenConnId()
-	}else{
-		newConnId = atomic.AddInt64(&f.connId, 1)
-	}
+	newConnId = f.genConnId()
 	if newConnId <= 0 {
 		log.Printf("group %v, can't gen new connect id\n", groupId)
 		return
 	}
 
-	//get or create group
-	groupObj, subErr := f.GetGroup(groupId)
-	if subErr != nil {
-		log.Printf("group %v, get group failed, err:%v\n", groupId, subErr.Error())
+	//get or create group, add new connect into it
+	//checked by connects limit, retry if group reclaimed before added
+	var groupObj iface.IGroup
+	for i := 0; i <= define.GroupJoinRetries; i++ {
+		groupObj, err = f.getGroupForJoin(groupId)
+		if err != nil || groupObj == nil {
+			log.Printf("group %v, get group failed, err:%v\n", groupId, err)
+			return
+		}
+		err = groupObj.AddConn(newConnId, conn)
+		if !errors.Is(err, errGroupRetired) {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("group %v, add connect failed, err:%v\n", groupId, err.Error())
 		return
 	}
-	if groupObj == nil {
-		log.Printf("group %v, can't get group object\n", groupId)
+	connector, _ := groupObj.GetConn(newConnId)
+	if connector == nil {
 		return
 	}
 
-	//add new connect into target bucket
-	groupObj.AddConn(newConnId, conn)
-
-	//keep the new connect active
-	select {}
+	//keep the new connect active until closed
+	<-connector.Done()
 }
 
 ////////////////
 //private func
 ////////////////
 
+//websocket request entry of multi groups mode
+//path group id is optional, join it if assigned
+func (f *Dynamic) multiEntry(conn *websocket.Conn) {
+	var (
+		groupId   string
+		newConnId int64
+		err       error
+	)
+	//check optional group id para
+	groupIdStr, _ := f.GetPathPara(conn, define.PathParaNameOfGroup)
+	if groupIdStr != "" {
+		groupId, err = f.getAndVerifyGroupId(conn)
+		if err != nil || groupId == "" {
+			log.Printf("group %v, verify group id failed", groupId)
+			return
+		}
+	}
+
+	//gen new connect id
+	newConnId = f.genConnId()
+	if newConnId <= 0 {
+		log.Printf("dynamic %v, can't gen new connect id\n", f.cfg.Uri)
+		return
+	}
+
+	//init new connector and sync into map with locker
+	connector := f.newMultiConnector(newConnId, conn)
+	f.Lock()
+	if f.connMap == nil {
+		f.Unlock()
+		connector.CloseWithCode(define.CloseCodeGoingAway, "server shutdown")
+		return
+	}
+	f.connMap[newConnId] = connector
+	f.Unlock()
+
+	//check and call the connected cb of outside
+	if f.cfg.CBForConnected != nil {
+		f.cfg.CBForConnected(f, "", connector)
+	}
+
+	//issue session token for resume
+	f.shared.IssueSession(connector)
+
+	//join path group and replay history assigned by query paras
+	if groupId != "" {
+		if groupObj, subErr := f.joinGroup(newConnId, groupId); subErr != nil {
+			log.Printf("group %v, join failed, err:%v\n", groupId, subErr.Error())
+		}else{
+			queryParas, _ := f.GetQueryParas(conn)
+			groupObj.Replay(newConnId, genHistoryQuery(queryParas))
+		}
+	}
+
+	//keep the new connect active until closed
+	<-connector.Done()
+}
+
+//init new connector of multi groups mode
+func (f *Dynamic) newMultiConnector(connId int64, conn *websocket.Conn) *Connector {
+	//setup connect config
+	cbForRead := func(connId int64, messageType int, data interface{}) error {
+		if ctrl := parseGroupControl(data); ctrl != nil {
+			return f.handleControl(connId, ctrl)
+		}
+		if f.cfg.CBForRead != nil {
+			return f.cfg.CBForRead(f, "", connId, messageType, data)
+		}
+		return nil
+	}
+	cbForClose := func(connId int64) error {
+		if f.parkConn(connId) {
+			return nil
+		}
+		return f.closeConn(connId)
+	}
+	cbForTooBig := func(connId int64) error {
+		if f.cfg.CBForTooBig != nil {
+			return f.cfg.CBForTooBig(f, "", connId)
+		}
+		return nil
+	}
+	cbForLimited := func(connId int64, kind int) error {
+		if f.cfg.CBForLimited != nil {
+			return f.cfg.CBForLimited(f, "", connId, kind)
+		}
+		return nil
+	}
+	connConf := &ConnConf{
+		MessageType: f.cfg.MessageType,
+		MaxMessageSize: f.cfg.MaxMessageSize,
+		MaxFramesPerMessage: f.cfg.MaxFramesPerMessage,
+		CBForRead: cbForRead,
+		CBForClosed: cbForClose,
+		CBForTooBig: cbForTooBig,
+		CBForLimited: cbForLimited,
+		Limiter: f.shared.Limiter,
+		Wheel: f.shared.Wheel,
+		IdleTimeout: f.cfg.IdleTimeout,
+		IdleMode: f.cfg.IdleMode,
+		PingInterval: f.cfg.PingInterval,
+		Rpc: f.shared.Rpc,
+		JsonRpc: f.shared.JsonRpc,
+		Event: f.shared.Event,
+		PubSub: f.shared.GetPubSub(f.cfg.EnablePubSub),
+		Reliable: f.shared.Reliable,
+		OrderedWrite: f.cfg.OrderedWrite,
+	}
+
+	return NewConnector(connConf, connId, conn)
+}
+
+//remove history of removed group
+func (f *Dynamic) removeHistory(groupId string) {
+	if f.shared.History == nil {
+		return
+	}
+	if err := f.shared.History.Remove(groupId); err != nil {
+		log.Printf("dynamic %v remove history of group %v failed, err:%v\n", f.cfg.Uri, groupId, err)
+	}
+}
+
+//join connect into group without history replay
+func (f *Dynamic) joinGroup(connId int64, groupId string) (iface.IGroup, error) {
+	//check
+	if connId <= 0 || groupId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if !f.cfg.MultiGroup {
+		return nil, errors.New("dynamic not in multi groups mode")
+	}
+
+	//get connector and group
+	connector, err := f.GetConn(connId)
+	if err != nil {
+		return nil, err
+	}
+	//retry if group reclaimed before joined
+	var groupObj iface.IGroup
+	for i := 0; i <= define.GroupJoinRetries; i++ {
+		groupObj, err = f.getGroupForJoin(groupId)
+		if err != nil {
+			return nil, err
+		}
+		err = groupObj.Join(connector)
+		if !errors.Is(err, errGroupRetired) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return groupObj, nil
+}
+
+//resume parked session by token query para
+func (f *Dynamic) resumeSession(conn *websocket.Conn) iface.IConnector {
+	if f.shared.Sessions == nil {
+		return nil
+	}
+	queryParas, _ := f.GetQueryParas(conn)
+	return f.shared.ResumeSession(queryParas.Get(define.QueryParaOfSession), conn)
+}
+
+//park connect of multi groups mode lost by network
+func (f *Dynamic) parkConn(connId int64) bool {
+	if f.shared.Sessions == nil {
+		return false
+	}
+	connector, _ := f.GetConn(connId)
+	if connector == nil {
+		return false
+	}
+	return f.shared.Sessions.Park(connector, f.resumeConn, func() {
+		f.closeConn(connId)
+	})
+}
+
+//resume parked connector of multi groups mode with new connect
+func (f *Dynamic) resumeConn(old iface.IConnector, conn *websocket.Conn) (iface.IConnector, error) {
+	//init new connector with same conn id
+	connId := old.GetConnId()
+	connector := f.newMultiConnector(connId, conn)
+	inheritConn(old, connector)
+
+	//replace parked connector with locker
+	f.Lock()
+	if _, ok := f.connMap[connId]; !ok {
+		f.Unlock()
+		connector.CloseWithCode(define.CloseCodeGoingAway, "session closed")
+		return nil, errors.New("no such connector")
+	}
+	f.connMap[connId] = connector
+	f.Unlock()
+
+	//replace in all joined groups
+	for _, groupId := range f.GetGroupsOfConn(connId) {
+		groupObj, _ := f.GetGroup(groupId)
+		if groupObj != nil {
+			groupObj.ReplaceConn(connector)
+		}
+	}
+
+	//take over shared components and notify outside
+	f.shared.ResumeConn(old, connector)
+	if f.cfg.CBForResumed != nil {
+		f.cfg.CBForResumed(f, "", connector)
+	}
+	return connector, nil
+}
+
+//remove and close connect of multi groups mode
+func (f *Dynamic) closeConn(connId int64) error {
+	//remove conn from map
+	f.Lock()
+	connector, ok := f.connMap[connId]
+	delete(f.connMap, connId)
+	f.Unlock()
+	if !ok || connector == nil {
+		//closed before
+		return errors.New("no such connector")
+	}
+
+	//leave all joined groups
+	for _, groupId := range f.GetGroupsOfConn(connId) {
+		f.LeaveGroup(connId, groupId)
+	}
+
+	//force close connect
+	connector.Close()
+	f.shared.ReleaseConn(connector)
+
+	//check and call the closed cb of outside
+	if f.cfg.CBForClosed != nil {
+		f.cfg.CBForClosed(f, "", connId, connector.GetCloseReason())
+	}
+	return nil
+}
+
+//parse control message of group
+//return nil if not control message
+func parseGroupControl(data interface{}) *gvar.ControlMsg {
+	var (
+		byteData []byte
+	)
+	switch v := data.(type) {
+	case []byte:
+		byteData = v
+	case string:
+		byteData = []byte(v)
+	case map[string]interface{}:
+		if _, ok := v["ctrl"]; !ok {
+			return nil
+		}
+		byteData, _ = json.Marshal(v)
+	default:
+		return nil
+	}
+	//group id may be number or string
+	rawCtrl := struct {
+		Ctrl    string             `json:"ctrl"`
+		Group   json.RawMessage    `json:"group"`
+		History *gvar.HistoryQuery `json:"history"`
+	}{}
+	if err := json.Unmarshal(byteData, &rawCtrl); err != nil {
+		return nil
+	}
+	switch rawCtrl.Ctrl {
+	case define.ControlOfJoin, define.ControlOfLeave, define.ControlOfHistory:
+	default:
+		return nil
+	}
+	ctrl := &gvar.ControlMsg{
+		Ctrl:    rawCtrl.Ctrl,
+		History: rawCtrl.History,
+	}
+	if err := json.Unmarshal(rawCtrl.Group, &ctrl.Group); err != nil {
+		ctrl.Group = string(rawCtrl.Group)
+	}
+	return ctrl
+}
+
+//handle control message and reply result
+func (f *Dynamic) handleControl(connId int64, ctrl *gvar.ControlMsg) error {
+	var (
+		err error
+	)
+	connector, err := f.GetConn(connId)
+	if err != nil {
+		return err
+	}
+
+	//run control opt
+	var groupObj iface.IGroup
+	reply := &gvar.ControlMsg{
+		Group: ctrl.Group,
+	}
+	switch ctrl.Ctrl {
+	case define.ControlOfJoin:
+		if ctrl.Group != "" && f.cfg.CBForVerifyGroup != nil {
+			err = f.cfg.CBForVerifyGroup(connector.GetConn(), f.cfg.Uri, ctrl.Group)
+		}
+		if err == nil {
+			groupObj, err = f.joinGroup(connId, ctrl.Group)
+		}
+		reply.Ctrl = define.ControlOfJoined
+	case define.ControlOfLeave:
+		err = f.LeaveGroup(connId, ctrl.Group)
+		reply.Ctrl = define.ControlOfLeft
+	case define.ControlOfHistory:
+		//history message is the reply if succeed
+		groupObj, err = f.GetGroup(ctrl.Group)
+		if err == nil {
+			err = replayHistory(f.shared, groupObj, connId, ctrl.History)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	if err != nil {
+		reply.Ctrl = define.ControlOfError
+		reply.Reason = err.Error()
+	}
+
+	//reply to client
+	if f.cfg.MessageType == gvar.MessageTypeOfJson {
+		err = connector.Write(reply, gvar.MessageTypeOfJson)
+	}else{
+		byteData, _ := json.Marshal(reply)
+		err = connector.Write(byteData)
+	}
+
+	//replay history after joined
+	if groupObj != nil && reply.Ctrl == define.ControlOfJoined {
+		groupObj.Replay(connId, ctrl.History)
+	}
+	return err
+}
+
+//get group for join, auto create if enabled
+func (f *Dynamic) getGroupForJoin(groupId string) (iface.IGroup, error) {
+	if !f.cfg.AutoCreateGroup {
+		return f.GetGroup(groupId)
+	}
+	groupObj, _, err := f.loadOrCreateGroup(groupId)
+	return groupObj, err
+}
+
+//get old group or create new one with locker
+//return group and is new or not
+func (f *Dynamic) loadOrCreateGroup(groupId string) (iface.IGroup, bool, error) {
+	//get or create with locker
+	f.Lock()
+	if f.groupMap == nil {
+		f.Unlock()
+		return nil, false, errors.New("dynamic had quit")
+	}
+	oldGroup, ok := f.groupMap[groupId]
+	if ok && oldGroup != nil {
+		f.Unlock()
+		return oldGroup, false, nil
+	}
+	newGroup := NewGroup(groupId, f.cfg, f.shared)
+	newGroup.key = atomic.AddInt64(&f.groupKey, 1)
+	newGroup.cbForEmpty = f.onGroupEmpty
+	f.groupMap[groupId] = newGroup
+	f.Unlock()
+
+	//check and call the created cb of outside
+	if f.cfg.CBForGroupCreated != nil {
+		f.cfg.CBForGroupCreated(newGroup, groupId)
+	}
+	return newGroup, true, nil
+}
+
+//schedule reclaim when group become empty
+func (f *Dynamic) onGroupEmpty(group *Group) {
+	if f.groupWheel == nil {
+		return
+	}
+	f.groupWheel.Add(group.key, f.cfg.EmptyGroupTTL, func() {
+		f.reclaimGroup(group)
+	})
+}
+
+//reclaim group if still empty
+func (f *Dynamic) reclaimGroup(group *Group) {
+	//check and remove with locker
+	groupId := group.GetId()
+	f.Lock()
+	groupObj, ok := f.groupMap[groupId]
+	if !ok || groupObj != iface.IGroup(group) || !group.retireIfEmpty() {
+		f.Unlock()
+		return
+	}
+	delete(f.groupMap, groupId)
+	f.Unlock()
+
+	//release group and notify outside
+	groupObj.Quit()
+	f.removeHistory(groupId)
+	if f.cfg.CBForGroupRemoved != nil {
+		f.cfg.CBForGroupRemoved(groupObj, groupId)
+	}
+}
+
+//gen new connect id
+func (f *Dynamic) genConnId() int64 {
+	if f.cfg.CBForGenConnId != nil {
+		return f.cfg.CBForGenConnId()
+	}
+	return atomic.AddInt64(&f.connId, 1)
+}
+
 //rebuild
 func (f *Dynamic) rebuild() {
 	//init new group map and release old map
-	newGroupMap := map[int64]iface.IGroup{}
+	newGroupMap := map[string]iface.IGroup{}
 	for k, v := range f.groupMap {
 		newGroupMap[k] = v
 	}
@@ -218,32 +873,65 @@ func (f *Dynamic) rebuild() {
 }
 
 //get and verify group id para
-func (f *Dynamic) getAndVerifyGroupId(conn *websocket.Conn) (int64, error) {
+func (f *Dynamic) getAndVerifyGroupId(conn *websocket.Conn) (string, error) {
 	//get group id from path para
 	groupId, err := f.GetPathPara(conn, define.PathParaNameOfGroup)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
-
-	//convert
-	groupIdInt, _ := strconv.ParseInt(groupId, 10, 64)
-	if groupIdInt <= 0 {
-		return 0, errors.New("invalid group id")
+	if groupId == "" || len(groupId) > define.GroupIdMaxSize {
+		return "", errors.New("invalid group id")
 	}
 
 	//check the cb for verify group and run it
 	if f.cfg != nil && f.cfg.CBForVerifyGroup != nil {
-		err = f.cfg.CBForVerifyGroup(conn, f.cfg.Uri, groupIdInt)
+		err = f.cfg.CBForVerifyGroup(conn, f.cfg.Uri, groupId)
 		if err != nil {
-			return 0, err
+			return "", err
 		}
-		return groupIdInt, nil
+		return groupId, nil
 	}
-	return groupIdInt, nil
+	return groupId, nil
 }
 
 //inter init
-func (f *Dynamic) interInit() {
+func (f *Dynamic) interInit(registry *OwnerRegistry, pubSub *PubSub, reliable *Reliable) {
 	//init inter counter
 	atomic.StoreInt64(&f.connId, 0)
+
+	//init connect admission
+	f.admission = NewAdmission(f.cfg.MaxConns, f.cfg.MaxConnsPerIp)
+
+	//init shared components
+	limiter := NewRateLimiter(f.cfg.ConnRateLimit, f.cfg.OwnerRateLimit, f.cfg.IpRateLimit)
+	f.shared = NewShared(limiter, f.cfg.IdleTimeout, registry, pubSub)
+	if f.cfg.MultiGroup {
+		f.shared.Members = NewMembership()
+	}
+	if f.cfg.EnableRpc {
+		f.shared.Rpc = NewRpcRouter()
+	}
+	if f.cfg.JsonRpc {
+		f.shared.JsonRpc = NewJsonRpcRouter()
+	}
+	if f.cfg.EnableEvent {
+		f.shared.Event = NewEventRouter()
+	}
+	if f.cfg.EnableReliable {
+		f.shared.Reliable = reliable
+	}
+	if f.cfg.ResumeGrace > 0 {
+		f.shared.Sessions = NewSessionStore(f.cfg.ResumeGrace)
+	}
+	if f.cfg.HistorySize > 0 {
+		f.shared.History = NewMemHistory(f.cfg.HistorySize, f.cfg.HistoryAge)
+	}
+	if f.cfg.OfflineSize > 0 {
+		f.shared.Offline = NewMemOffline(f.cfg.OfflineSize, f.cfg.OfflineTTL)
+	}
+
+	//init empty group reclaim
+	if f.cfg.EmptyGroupTTL > 0 {
+		f.groupWheel = NewTimingWheel(define.IdleWheelTick, define.IdleWheelSlots)
+	}
 }
\ No newline at end of file
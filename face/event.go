@@ -0,0 +1,232 @@
+package face
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * named event face
+ * - envelope like `{"event":"chat.send","data":{},"ack":1}`
+ * - event handlers shared by router or dynamic
+ * - ack callbacks of one connect
+ */
+
+//event not enabled error
+var ErrEventNotEnabled = errors.New("event not enabled")
+
+//face info
+type EventRouter struct {
+	handlers map[string]gvar.EventHandler //event -> handler
+	sync.RWMutex
+}
+
+//construct
+func NewEventRouter() *EventRouter {
+	this := &EventRouter{
+		handlers: map[string]gvar.EventHandler{},
+	}
+	return this
+}
+
+//register event handler
+func (f *EventRouter) On(event string, handler gvar.EventHandler) error {
+	//check
+	if event == "" || handler == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//register with locker
+	f.Lock()
+	defer f.Unlock()
+	f.handlers[event] = handler
+	return nil
+}
+
+//dispatch event to handler
+//return false if no handler of event
+func (f *EventRouter) Dispatch(
+	connector interface{},
+	msg *gvar.EventMsg,
+	write func(msg *gvar.EventMsg) error) bool {
+	var (
+		m any = nil
+	)
+	//get handler
+	f.RLock()
+	handler, ok := f.handlers[msg.Event]
+	f.RUnlock()
+	if !ok || handler == nil {
+		return false
+	}
+
+	//ack func, reply only once
+	var ackOnce sync.Once
+	ack := func(data interface{}) error {
+		if msg.Ack <= 0 {
+			return nil
+		}
+		var err error
+		ackOnce.Do(func() {
+			reply := &gvar.EventMsg{
+				Ack: msg.Ack,
+			}
+			reply.Data, err = json.Marshal(data)
+			if err == nil {
+				err = write(reply)
+			}
+		})
+		return err
+	}
+
+	//run handler
+	defer func() {
+		if pErr := recover(); pErr != m {
+			log.Printf("event %v handler panic, err:%v\n", msg.Event, pErr)
+		}
+	}()
+	handler(connector, msg.Data, ack)
+	return true
+}
+
+//face info
+type EventAcks struct {
+	seq   int64
+	cbMap map[int64]func(data json.RawMessage) //ack id -> callback
+	sync.Mutex
+}
+
+//construct
+func NewEventAcks() *EventAcks {
+	this := &EventAcks{
+		cbMap: map[int64]func(data json.RawMessage){},
+	}
+	return this
+}
+
+//quit, drop all waiting callbacks
+func (f *EventAcks) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	f.cbMap = map[int64]func(data json.RawMessage){}
+}
+
+//add ack callback, removed if no ack in time
+//return ack id
+func (f *EventAcks) Add(cb func(data json.RawMessage)) int64 {
+	id := atomic.AddInt64(&f.seq, 1)
+	f.Lock()
+	f.cbMap[id] = cb
+	f.Unlock()
+	time.AfterFunc(define.EventAckSeconds*time.Second, func() {
+		f.Lock()
+		defer f.Unlock()
+		delete(f.cbMap, id)
+	})
+	return id
+}
+
+//resolve ack reply
+func (f *EventAcks) Resolve(msg *gvar.EventMsg) bool {
+	var (
+		m any = nil
+	)
+	f.Lock()
+	cb, ok := f.cbMap[msg.Ack]
+	delete(f.cbMap, msg.Ack)
+	f.Unlock()
+	if !ok || cb == nil {
+		return false
+	}
+	defer func() {
+		if pErr := recover(); pErr != m {
+			log.Printf("event ack %v callback panic, err:%v\n", msg.Ack, pErr)
+		}
+	}()
+	cb(msg.Data)
+	return true
+}
+
+//gen event message, with ack id if has callback
+func GenEventMsg(event string, data interface{}, acks *EventAcks, ackCbs ...func(data json.RawMessage)) (*gvar.EventMsg, error) {
+	//check
+	if event == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//init event message
+	msg := &gvar.EventMsg{
+		Event: event,
+	}
+	if data != nil {
+		byteData, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = byteData
+	}
+	if len(ackCbs) > 0 && ackCbs[0] != nil {
+		if acks == nil {
+			return nil, ErrEventNotEnabled
+		}
+		msg.Ack = acks.Add(ackCbs[0])
+	}
+	return msg, nil
+}
+
+//parse event message
+//return nil if not event message
+func ParseEventMsg(data interface{}) *gvar.EventMsg {
+	var (
+		byteData []byte
+	)
+	switch v := data.(type) {
+	case []byte:
+		byteData = v
+	case string:
+		byteData = []byte(v)
+	case map[string]interface{}:
+		_, hasEvent := v["event"]
+		_, hasAck := v["ack"]
+		if !hasEvent && !hasAck {
+			return nil
+		}
+		byteData, _ = json.Marshal(v)
+	default:
+		return nil
+	}
+	if len(byteData) <= 0 || byteData[0] != '{' {
+		return nil
+	}
+	msg := &gvar.EventMsg{}
+	if err := json.Unmarshal(byteData, msg); err != nil {
+		return nil
+	}
+	if msg.Event == "" && msg.Ack <= 0 {
+		return nil
+	}
+	return msg
+}
+
+//encode json value as cast data by message type
+func genJsonCastData(messageType int, v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	//json message encode again, so use raw message
+	if messageType == gvar.MessageTypeOfJson {
+		return json.RawMessage(data), nil
+	}
+	return data, nil
+}
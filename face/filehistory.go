@@ -0,0 +1,242 @@
+package face
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * file backed history store of groups
+ * - one json lines file per group, messages cached in memory
+ * - file compacted when lines over twice of max messages
+ */
+
+//history file of one group
+type historyFile struct {
+	file  *os.File
+	lines int
+}
+
+//face info
+type FileHistory struct {
+	dir     string
+	mem     *MemHistory
+	fileMap map[string]*historyFile //groupId -> file
+	sync.Mutex
+}
+
+//construct
+//zero maxAge means no age limit
+func NewFileHistory(dir string, maxMessages int, maxAge time.Duration) (*FileHistory, error) {
+	//check
+	if dir == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	this := &FileHistory{
+		dir:     dir,
+		mem:     NewMemHistory(maxMessages, maxAge),
+		fileMap: map[string]*historyFile{},
+	}
+	return this, nil
+}
+
+//quit
+func (f *FileHistory) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	for k, v := range f.fileMap {
+		v.file.Close()
+		delete(f.fileMap, k)
+	}
+	f.mem.Quit()
+}
+
+//append message of group
+func (f *FileHistory) Append(groupId string, data json.RawMessage) (*gvar.HistoryMsg, error) {
+	//check
+	if groupId == "" || data == nil {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//append with locker
+	f.Lock()
+	defer f.Unlock()
+	hf, err := f.open(groupId)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := f.mem.Append(groupId, data)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = hf.file.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	hf.lines++
+
+	//compact file, skip if no message limit
+	//message had written, so compact failure only logged
+	if f.mem.maxMessages > 0 && hf.lines > 2*f.mem.maxMessages {
+		if err = f.compact(groupId, hf); err != nil {
+			log.Printf("compact history file of group %v failed, err:%v\n", groupId, err)
+		}
+	}
+	return msg, nil
+}
+
+//query messages of group
+func (f *FileHistory) Query(groupId string, query *gvar.HistoryQuery) ([]*gvar.HistoryMsg, error) {
+	//check
+	if groupId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//load file if need
+	f.Lock()
+	_, err := f.open(groupId)
+	f.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return f.mem.Query(groupId, query)
+}
+
+//remove all messages and file of group
+func (f *FileHistory) Remove(groupId string) error {
+	f.Lock()
+	defer f.Unlock()
+	if hf, ok := f.fileMap[groupId]; ok {
+		hf.file.Close()
+		delete(f.fileMap, groupId)
+	}
+	f.mem.Remove(groupId)
+	err := os.Remove(f.genPath(groupId))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//open file of group and load messages without locker
+func (f *FileHistory) open(groupId string) (*historyFile, error) {
+	if hf, ok := f.fileMap[groupId]; ok {
+		return hf, nil
+	}
+
+	//load old messages
+	path := f.genPath(groupId)
+	msgs, err := f.load(path)
+	if err != nil {
+		return nil, err
+	}
+	f.mem.load(groupId, msgs)
+
+	//open for append
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	hf := &historyFile{
+		file:  file,
+		lines: len(msgs),
+	}
+	f.fileMap[groupId] = hf
+	return hf, nil
+}
+
+//read messages from file, broken lines skipped
+func (f *FileHistory) load(path string) ([]*gvar.HistoryMsg, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*gvar.HistoryMsg{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	msgs := make([]*gvar.HistoryMsg, 0)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			msg := &gvar.HistoryMsg{}
+			if json.Unmarshal(line, msg) == nil {
+				msgs = append(msgs, msg)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+//rewrite file with cached messages without locker
+func (f *FileHistory) compact(groupId string, hf *historyFile) error {
+	//write into temp file
+	path := f.genPath(groupId)
+	tmpPath := path + ".tmp"
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmpFile)
+	msgs := f.mem.snapshot(groupId)
+	for _, v := range msgs {
+		line, _ := json.Marshal(v)
+		writer.Write(append(line, '\n'))
+	}
+	if err = writer.Flush(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	tmpFile.Close()
+
+	//replace old file and reopen
+	//old file kept and reopened if rename failed
+	hf.file.Close()
+	renameErr := os.Rename(tmpPath, path)
+	if renameErr != nil {
+		os.Remove(tmpPath)
+	}
+	hf.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		delete(f.fileMap, groupId)
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	hf.lines = len(msgs)
+	return nil
+}
+
+//gen file path of group, group id hex encoded
+func (f *FileHistory) genPath(groupId string) string {
+	return filepath.Join(f.dir, hex.EncodeToString([]byte(groupId))+define.HistoryFileSuffix)
+}
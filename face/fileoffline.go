@@ -0,0 +1,282 @@
+package face
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * file backed offline message store
+ * - append only wal file, replayed into memory when start
+ * - wal rewritten by kept messages when garbage records too many
+ */
+
+//wal record
+type offlineRecord struct {
+	Op      string           `json:"op"`
+	OwnerId int64            `json:"owner"`
+	Msg     *gvar.OfflineMsg   `json:"msg,omitempty"`
+	Msgs    []*gvar.OfflineMsg `json:"msgs,omitempty"` //for push front
+}
+
+//face info
+type FileOffline struct {
+	path    string
+	mem     *MemOffline
+	file    *os.File
+	records int //total records of wal
+	sync.Mutex
+}
+
+//construct
+//zero ttl means never expired
+func NewFileOffline(path string, maxMessages int, ttl time.Duration) (*FileOffline, error) {
+	//check
+	if path == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	this := &FileOffline{
+		path: path,
+		mem:  NewMemOffline(maxMessages, ttl),
+	}
+
+	//replay wal and open for append
+	if err := this.load(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	this.file = file
+	this.tryCompact()
+	return this, nil
+}
+
+//quit
+func (f *FileOffline) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	if f.file != nil {
+		f.file.Sync()
+		f.file.Close()
+		f.file = nil
+	}
+	f.mem.Quit()
+}
+
+//push message of owner
+func (f *FileOffline) Push(ownerId int64, msg *gvar.OfflineMsg) error {
+	//check
+	if ownerId <= 0 || msg == nil {
+		return errors.New("invalid parameter")
+	}
+	if msg.Time <= 0 {
+		msg.Time = time.Now().UnixMilli()
+	}
+
+	//write wal and push with locker
+	f.Lock()
+	defer f.Unlock()
+	err := f.writeRecord(&offlineRecord{
+		Op:      define.OfflineOfPush,
+		OwnerId: ownerId,
+		Msg:     msg,
+	})
+	if err != nil {
+		return err
+	}
+	if err = f.mem.Push(ownerId, msg); err != nil {
+		return err
+	}
+	f.tryCompact()
+	return nil
+}
+
+//push messages of owner before kept messages
+func (f *FileOffline) PushFront(ownerId int64, msgs []*gvar.OfflineMsg) error {
+	//check
+	if ownerId <= 0 {
+		return errors.New("invalid parameter")
+	}
+	if len(msgs) <= 0 {
+		return nil
+	}
+
+	//write wal and push with locker
+	f.Lock()
+	defer f.Unlock()
+	err := f.writeRecord(&offlineRecord{
+		Op:      define.OfflineOfPushFront,
+		OwnerId: ownerId,
+		Msgs:    msgs,
+	})
+	if err != nil {
+		return err
+	}
+	if err = f.mem.PushFront(ownerId, msgs); err != nil {
+		return err
+	}
+	f.tryCompact()
+	return nil
+}
+
+//take all messages of owner
+func (f *FileOffline) Take(ownerId int64) ([]*gvar.OfflineMsg, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.mem.GetTotal(ownerId) <= 0 {
+		return nil, nil
+	}
+	err := f.writeRecord(&offlineRecord{
+		Op:      define.OfflineOfTake,
+		OwnerId: ownerId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := f.mem.Take(ownerId)
+	if err != nil {
+		return nil, err
+	}
+	f.tryCompact()
+	return msgs, nil
+}
+
+//get total messages of owner
+func (f *FileOffline) GetTotal(ownerId int64) int {
+	return f.mem.GetTotal(ownerId)
+}
+
+//write wal record without locker
+func (f *FileOffline) writeRecord(record *offlineRecord) error {
+	if f.file == nil {
+		return errors.New("offline store had quit")
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err = f.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	f.records++
+	return nil
+}
+
+//replay wal into memory, broken lines skipped
+func (f *FileOffline) load() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			f.replay(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//replay one wal record
+func (f *FileOffline) replay(line []byte) {
+	record := &offlineRecord{}
+	if json.Unmarshal(line, record) != nil {
+		return
+	}
+	f.records++
+	switch record.Op {
+	case define.OfflineOfPush:
+		if record.Msg != nil {
+			f.mem.Push(record.OwnerId, record.Msg)
+		}
+	case define.OfflineOfPushFront:
+		if len(record.Msgs) > 0 {
+			f.mem.PushFront(record.OwnerId, record.Msgs)
+		}
+	case define.OfflineOfTake:
+		f.mem.Take(record.OwnerId)
+	}
+}
+
+//compact wal when garbage records too many without locker
+//garbage records include taken, dropped by limit and expired messages
+//messages had written, so compact failure only logged
+func (f *FileOffline) tryCompact() {
+	if f.records-f.mem.getTotalAll() <= define.OfflineCompactRecords {
+		return
+	}
+	if err := f.compact(); err != nil {
+		log.Printf("compact offline wal failed, err:%v\n", err)
+	}
+}
+
+//rewrite wal with kept messages without locker
+func (f *FileOffline) compact() error {
+	//write into temp file
+	tmpPath := f.path + ".tmp"
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmpFile)
+	records := 0
+	for ownerId, msgs := range f.mem.snapshot() {
+		for _, v := range msgs {
+			line, _ := json.Marshal(&offlineRecord{
+				Op:      define.OfflineOfPush,
+				OwnerId: ownerId,
+				Msg:     v,
+			})
+			writer.Write(append(line, '\n'))
+			records++
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	tmpFile.Sync()
+	tmpFile.Close()
+
+	//replace old wal and reopen
+	f.file.Close()
+	if err = os.Rename(tmpPath, f.path); err != nil {
+		f.file, _ = os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		return err
+	}
+	f.file, err = os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	f.records = records
+	return nil
+}
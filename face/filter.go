@@ -0,0 +1,96 @@
+package face
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * cast filter face
+ * - exclude connects or owners
+ * - match connect props and filter predicate
+ */
+
+//one prop match expression
+type propMatch struct {
+	kind   string
+	val    string
+	negate bool
+}
+
+//face info
+type castFilter struct {
+	excludeConnIds  map[int64]bool
+	excludeOwnerIds map[int64]bool
+	propMatches     []propMatch
+	filter          func(connector interface{}) bool
+}
+
+//construct
+//return nil if no filter condition
+func newCastFilter(data *gvar.MsgData) *castFilter {
+	if len(data.ExcludeConnIds) <= 0 && len(data.ExcludeOwnerIds) <= 0 &&
+		len(data.PropMatches) <= 0 && data.Filter == nil {
+		return nil
+	}
+	this := &castFilter{
+		excludeConnIds:  map[int64]bool{},
+		excludeOwnerIds: map[int64]bool{},
+		filter:          data.Filter,
+	}
+	for _, connId := range data.ExcludeConnIds {
+		this.excludeConnIds[connId] = true
+	}
+	for _, ownerId := range data.ExcludeOwnerIds {
+		this.excludeOwnerIds[ownerId] = true
+	}
+	for _, expr := range data.PropMatches {
+		this.propMatches = append(this.propMatches, parsePropMatch(expr))
+	}
+	return this
+}
+
+//check connector should receive message or not
+func (f *castFilter) allow(connector iface.IConnector) bool {
+	if f == nil {
+		return true
+	}
+	if f.excludeConnIds[connector.GetConnId()] {
+		return false
+	}
+	if f.excludeOwnerIds[connector.GetOwnerId()] {
+		return false
+	}
+	for _, match := range f.propMatches {
+		val, _ := connector.GetProp(match.kind)
+		isEqual := val != nil && fmt.Sprint(val) == match.val
+		if isEqual == match.negate {
+			return false
+		}
+	}
+	if f.filter != nil && !f.filter(connector) {
+		return false
+	}
+	return true
+}
+
+//parse prop match expression like `role=admin` or `role!=guest`
+func parsePropMatch(expr string) propMatch {
+	if idx := strings.Index(expr, "!="); idx >= 0 {
+		return propMatch{
+			kind:   strings.TrimSpace(expr[:idx]),
+			val:    strings.TrimSpace(expr[idx+2:]),
+			negate: true,
+		}
+	}
+	kind, val, _ := strings.Cut(expr, "=")
+	return propMatch{
+		kind: strings.TrimSpace(kind),
+		val:  strings.TrimSpace(val),
+	}
+}
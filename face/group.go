@@ -1,12 +1,14 @@
 package face
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -24,25 +26,35 @@ import (
  * - all connects in one group
  */
 
+//group reclaimed before join, join again by new group
+var errGroupRetired = errors.New("group had retired")
+
 //face info
 type Group struct {
-	groupId        int64
+	groupId        string
+	key            int64 //inter unique key, used by timing wheel
 	conf           *gvar.GroupConf //group config reference
+	shared         *Shared         //shared components of parent dynamic
 	connMap        map[int64]iface.IConnector //connId -> IConnector
-	connOwnerMap   map[int64]int64 //ownerId -> connId
+	connOwnerMap   ownerIndex      //ownerId -> connIds
 	writeChan      chan gvar.MsgData
 	writeCloseChan chan bool
+	cbForEmpty     func(group *Group) //notify parent when group become empty
+	adding         int                //connects in adding, counted by conns limit
+	castSeq        int64              //cast seq when history disabled
+	castLocker     sync.Mutex         //keep cast seq in write chan order
 	sync.RWMutex
 	Util
 }
 
 //construct
-func NewGroup(groupId int64, cfg *gvar.GroupConf) *Group {
+func NewGroup(groupId string, cfg *gvar.GroupConf, shared *Shared) *Group {
 	this := &Group{
 		groupId:        groupId,
 		conf:           cfg,
+		shared:         shared,
 		connMap:        map[int64]iface.IConnector{},
-		connOwnerMap:   map[int64]int64{},
+		connOwnerMap:   ownerIndex{},
 		writeChan:      make(chan gvar.MsgData, define.DefaultGroupWriteChan),
 		writeCloseChan: make(chan bool, 1),
 	}
@@ -52,20 +64,35 @@ func NewGroup(groupId int64, cfg *gvar.GroupConf) *Group {
 
 //quit
 func (f *Group) Quit() {
-	//force close main loop
+	//force close main loop and drop scheduled casts
 	close(f.writeCloseChan)
+	f.shared.Scheduler.CancelTarget(f.groupId)
 
-	//just del record
+	//release old map with locker
 	f.Lock()
-	defer f.Unlock()
-	for _, v := range f.connMap {
-		if v != nil {
-			v.Close()
+	connMap := f.connMap
+	f.connMap = nil
+	f.Unlock()
+
+	//multi groups mode, members leave without close
+	if f.conf != nil && f.conf.MultiGroup {
+		for connId := range connMap {
+			f.afterLeave(connId)
 		}
+		return
 	}
 
-	//release old map
-	f.connMap = nil
+	//force close connects and notify outside
+	for connId, v := range connMap {
+		if v == nil {
+			continue
+		}
+		v.CloseWithCode(define.CloseCodeGoingAway, "server shutdown")
+		f.shared.ReleaseConn(v)
+		if f.conf != nil && f.conf.CBForClosed != nil {
+			f.conf.CBForClosed(f, f.groupId, connId, v.GetCloseReason())
+		}
+	}
 }
 
 //broadcast to connections by condition
@@ -84,16 +111,88 @@ func (f *Group) Cast(data *gvar.MsgData) error {
 		return fmt.Errorf("group %v write chan had closed", f.groupId)
 	}
 
-	//send to write chan
-	f.writeChan <- *data
+	//record history, keep for offline owners
+	//and send to write chan in seq order
+	f.castLocker.Lock()
+	defer f.castLocker.Unlock()
+	historyMsg := f.recordHistory(data)
+	f.shared.KeepOffline(data, f.conf.MessageType, func(ownerId int64) bool {
+		conns, _ := f.GetConnsByOwnerId(ownerId)
+		return len(conns) > 0
+	})
+	f.writeChan <- f.stampSeq(data, historyMsg)
 	return nil
 }
 
+//get history messages of group
+func (f *Group) GetHistory(query *gvar.HistoryQuery) ([]*gvar.HistoryMsg, error) {
+	if f.shared.History == nil {
+		return nil, errors.New("group history not enabled")
+	}
+	return f.shared.History.Query(f.groupId, query)
+}
+
+//replay history messages to connector
+//nil query means replay last `HistoryReplay` messages of conf
+func (f *Group) Replay(connId int64, query *gvar.HistoryQuery) error {
+	connector, err := f.GetConn(connId)
+	if err != nil {
+		return err
+	}
+	return f.replay(connector, query)
+}
+
+//cast at assigned time, return id as cancel handle
+//scheduled casts canceled when group quit
+func (f *Group) CastAt(at time.Time, data *gvar.MsgData) (int64, error) {
+	return f.shared.Scheduler.Add(at, f.groupId, data, f.Cast)
+}
+
+//cast after delay, return id as cancel handle
+func (f *Group) CastAfter(delay time.Duration, data *gvar.MsgData) (int64, error) {
+	return f.CastAt(time.Now().Add(delay), data)
+}
+
+//cancel scheduled cast of group
+func (f *Group) CancelCast(id int64) error {
+	return f.shared.Scheduler.Cancel(id, f.groupId)
+}
+
+//get scheduled casts of group, ordered by send time
+func (f *Group) GetScheduledCasts() []*gvar.ScheduledMsg {
+	return f.shared.Scheduler.GetScheduled(f.groupId)
+}
+
+//cast and wait delivery report
+func (f *Group) CastWithReport(data *gvar.MsgData) (*gvar.DeliveryReport, error) {
+	return castWithReport(f.Cast, data)
+}
+
+//emit named event to all connects of group
+func (f *Group) Emit(event string, data interface{}) error {
+	msg, err := GenEventMsg(event, data, nil)
+	if err != nil {
+		return err
+	}
+	castData, err := genJsonCastData(f.conf.MessageType, msg)
+	if err != nil {
+		return err
+	}
+	return f.Cast(&gvar.MsgData{
+		Data: castData,
+	})
+}
+
 //get group id
-func (f *Group) GetId() int64 {
+func (f *Group) GetId() string {
 	return f.groupId
 }
 
+//get group id as int64
+func (f *Group) GetIntId() (int64, error) {
+	return strconv.ParseInt(f.groupId, 10, 64)
+}
+
 //get total connects
 func (f *Group) GetTotal() int {
 	f.RLock()
@@ -113,21 +212,153 @@ func (f *Group) SetOwner(connId, ownerId int64) error {
 	if connector == nil {
 		return errors.New("can't get connector by id")
 	}
+
+	//check owner policy and set owner with owner locker
+	unlock := f.shared.owners.lock(ownerId)
+	olds, _ := f.GetConnsByOwnerId(ownerId)
+	kicks, err := checkOwnerPolicy(f.conf.OwnerPolicy, connId, olds)
+	if err != nil {
+		unlock()
+		connector.CloseWithCode(define.CloseCodePolicyViolation, err.Error())
+		return err
+	}
+	oldOwnerId := f.setOwnerIndex(connector, ownerId)
+	unlock()
+
+	//kick and bind owner without locker, it may write to connector
+	kickOwnerConns(kicks)
+	f.shared.BindOwner(oldOwnerId, ownerId, connector)
+	return nil
+}
+
+//set owner of connector and update owner index with locker
+//return old owner id, bind owner should be called after it
+func (f *Group) setOwnerIndex(connector iface.IConnector, ownerId int64) int64 {
+	connId := connector.GetConnId()
+	f.Lock()
+	defer f.Unlock()
+	oldOwnerId := connector.GetOwnerId()
+	if oldOwnerId > 0 {
+		f.connOwnerMap.remove(oldOwnerId, connId)
+	}
 	connector.SetOwnerId(ownerId)
+	f.connOwnerMap.add(ownerId, connId, time.Now().UnixNano())
+	return oldOwnerId
+}
+
+//join connector of dynamic, only for multi groups mode
+func (f *Group) Join(connector iface.IConnector) error {
+	//check
+	if connector == nil {
+		return errors.New("invalid parameter")
+	}
+	if f.conf == nil || !f.conf.MultiGroup {
+		return errors.New("group not in multi groups mode")
+	}
+	connId := connector.GetConnId()
+
+	//check owner policy of owned connect and add with owner locker
+	if ownerId := connector.GetOwnerId(); ownerId > 0 {
+		unlock := f.shared.owners.lock(ownerId)
+		olds, _ := f.GetConnsByOwnerId(ownerId)
+		kicks, err := checkOwnerPolicy(f.conf.OwnerPolicy, connId, olds)
+		if err != nil {
+			unlock()
+			connector.CloseWithCode(define.CloseCodePolicyViolation, err.Error())
+			return err
+		}
+		err = f.addJoined(connector)
+		unlock()
+		if err != nil {
+			return err
+		}
+		kickOwnerConns(kicks)
+	}else if err := f.addJoined(connector); err != nil {
+		return err
+	}
+
+	//update membership and notify outside
+	if f.shared.Members != nil {
+		f.shared.Members.Add(connId, f.groupId)
+	}
+	if f.conf.CBForJoin != nil {
+		f.conf.CBForJoin(f, f.groupId, connector)
+	}
+	return nil
+}
 
+//add joined connector into map with locker
+func (f *Group) addJoined(connector iface.IConnector) error {
+	connId := connector.GetConnId()
 	f.Lock()
 	defer f.Unlock()
-	f.connOwnerMap[ownerId] = connId
+	if f.connMap == nil {
+		return errGroupRetired
+	}
+	if _, ok := f.connMap[connId]; ok {
+		return errors.New("connector had joined")
+	}
+	if f.conf.MaxConnsPerGroup > 0 && len(f.connMap)+f.adding >= f.conf.MaxConnsPerGroup {
+		return errors.New("group is full")
+	}
+	f.connMap[connId] = connector
+	if ownerId := connector.GetOwnerId(); ownerId > 0 {
+		f.connOwnerMap.add(ownerId, connId, time.Now().UnixNano())
+	}
+	return nil
+}
+
+//leave connector without close, only for multi groups mode
+func (f *Group) Leave(connId int64) error {
+	//check
+	if connId <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//remove conn from map
+	f.Lock()
+	connector, ok := f.connMap[connId]
+	delete(f.connMap, connId)
+	if ok && connector != nil {
+		f.connOwnerMap.remove(connector.GetOwnerId(), connId)
+	}
+	isEmpty := len(f.connMap) <= 0
+	f.Unlock()
+	if !ok || connector == nil {
+		return errors.New("no such connector")
+	}
+	f.afterLeave(connId)
+	if isEmpty {
+		f.notifyEmpty()
+	}
 	return nil
 }
 
 //close old connect
+//connect lost by network will be parked if session resume enabled
 func (f *Group) CloseConn(connId int64) error {
 	//check
 	if connId <= 0 {
 		return errors.New("invalid parameter")
 	}
 
+	//multi groups mode, leave and close, dynamic will do the rest
+	if f.conf != nil && f.conf.MultiGroup {
+		connector, _ := f.GetConn(connId)
+		if err := f.Leave(connId); err != nil {
+			return err
+		}
+		connector.Close()
+		return nil
+	}
+	if f.parkConn(connId) {
+		return nil
+	}
+	return f.closeConn(connId)
+}
+
+//remove and close connect
+func (f *Group) closeConn(connId int64) error {
 	//hit gc rate
 	gcRate := rand.Intn(define.FullPercent)
 	needRebuildNewMap := false
@@ -135,52 +366,68 @@ func (f *Group) CloseConn(connId int64) error {
 		needRebuildNewMap = true
 	}
 
-	//check and call the closed cb of outside
-	if f.conf != nil && f.conf.CBForClosed != nil {
-		f.conf.CBForClosed(f, f.groupId, connId)
-	}
-
-	//get conn obj
-	connector, _ := f.GetConn(connId)
-	if connector != nil {
-		connector.Close()
-	}
-
 	//remove conn from map
 	f.Lock()
+	connector, ok := f.connMap[connId]
 	delete(f.connMap, connId)
-	if connector != nil {
-		delete(f.connOwnerMap, connector.GetOwnerId())
+	if ok && connector != nil {
+		f.connOwnerMap.remove(connector.GetOwnerId(), connId)
 	}
+	isEmpty := len(f.connMap) <= 0
 	f.Unlock()
+	if !ok || connector == nil {
+		//closed before
+		return errors.New("no such connector")
+	}
 
-	if needRebuildNewMap || len(f.connMap) <= 0 {
+	//force close connect
+	connector.Close()
+	f.shared.ReleaseConn(connector)
+
+	//check and call the closed cb of outside
+	if f.conf != nil && f.conf.CBForClosed != nil {
+		f.conf.CBForClosed(f, f.groupId, connId, connector.GetCloseReason())
+	}
+
+	if needRebuildNewMap || isEmpty {
 		f.rebuild()
 	}
+	if isEmpty {
+		f.notifyEmpty()
+	}
 	return nil
 }
 
-//get connector by owner id
+//get newest connector by owner id
 func (f *Group) GetConnByOwnerId(ownerId int64) (iface.IConnector, error) {
+	connectors, err := f.GetConnsByOwnerId(ownerId)
+	if err != nil {
+		return nil, err
+	}
+	return connectors[0], nil
+}
+
+//get all connectors by owner id, newest first
+func (f *Group) GetConnsByOwnerId(ownerId int64) ([]iface.IConnector, error) {
 	//check
 	if ownerId <= 0 {
 		return nil, errors.New("invalid parameter")
 	}
 
-	//loop map to found
+	//get by owner index with locker
 	f.RLock()
 	defer f.RUnlock()
-	connId, ok := f.connOwnerMap[ownerId]
-	if !ok || connId <= 0 {
-		return nil, errors.New("can't get owner id")
+	connectors := make([]iface.IConnector, 0)
+	for _, connId := range f.connOwnerMap.getConnIds(ownerId) {
+		v, ok := f.connMap[connId]
+		if ok && v != nil {
+			connectors = append(connectors, v)
+		}
 	}
-
-	//get conn by id
-	connector, sok := f.connMap[connId]
-	if !sok || connector == nil {
-		return nil, errors.New("can't get connector by id")
+	if len(connectors) <= 0 {
+		return nil, errors.New("can't get connector by owner id")
 	}
-	return connector, nil
+	return connectors, nil
 }
 
 //get old connect
@@ -207,8 +454,78 @@ func (f *Group) AddConn(connId int64, conn *websocket.Conn, timeouts ...time.Dur
 		return errors.New("invalid parameter")
 	}
 
+	//check conns limit and reserve with locker
+	f.Lock()
+	if f.connMap == nil {
+		f.Unlock()
+		return errGroupRetired
+	}
+	if f.conf != nil && f.conf.MaxConnsPerGroup > 0 && len(f.connMap)+f.adding >= f.conf.MaxConnsPerGroup {
+		f.Unlock()
+		f.CloseConnWithCode(conn, define.CloseCodeTryAgainLater, "group is full")
+		return errors.New("group is full")
+	}
+	f.adding++
+	f.Unlock()
+
+	//init new connector
+	connector := f.newConnector(connId, conn, timeouts...)
+
+	//sync into bucket map with locker
+	f.Lock()
+	f.adding--
+	if f.connMap == nil {
+		f.Unlock()
+		connector.CloseWithCode(define.CloseCodeGoingAway, "group removed")
+		return fmt.Errorf("group %v had quit", f.groupId)
+	}
+	f.connMap[connId] = connector
+	f.Unlock()
+
+	//check and call the connected cb of outside
+	if f.conf != nil && f.conf.CBForConnected != nil {
+		f.conf.CBForConnected(f, f.groupId, connector)
+	}
+
+	//issue session token for resume
+	f.shared.IssueSession(connector)
+
+	//replay history assigned by query paras
+	queryParas, _ := f.GetQueryParas(conn)
+	f.replay(connector, genHistoryQuery(queryParas))
+	return nil
+}
+
+//replace connector with same conn id, used by session resume
+func (f *Group) ReplaceConn(connector iface.IConnector) error {
+	//check
+	if connector == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//replace with locker
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.connMap[connector.GetConnId()]; !ok {
+		return errors.New("no such connector")
+	}
+	f.connMap[connector.GetConnId()] = connector
+	return nil
+}
+
+////////////////
+//private func
+////////////////
+
+//init new connector with group config
+func (f *Group) newConnector(connId int64, conn *websocket.Conn, timeouts ...time.Duration) *Connector {
 	//setup connect config
 	cbForRead := func(connId int64, messageType int, data interface{}) error {
+		if f.shared.History != nil {
+			if ctrl := parseGroupControl(data); ctrl != nil && ctrl.Ctrl == define.ControlOfHistory {
+				return replayHistory(f.shared, f, connId, ctrl.History)
+			}
+		}
 		if f.conf.CBForRead != nil {
 			return f.conf.CBForRead(f, f.groupId, connId, messageType, data)
 		}
@@ -217,37 +534,208 @@ func (f *Group) AddConn(connId int64, conn *websocket.Conn, timeouts ...time.Dur
 	cbForClose := func(connId int64) error {
 		return f.CloseConn(connId)
 	}
+	cbForTooBig := func(connId int64) error {
+		if f.conf.CBForTooBig != nil {
+			return f.conf.CBForTooBig(f, f.groupId, connId)
+		}
+		return nil
+	}
+	cbForLimited := func(connId int64, kind int) error {
+		if f.conf.CBForLimited != nil {
+			return f.conf.CBForLimited(f, f.groupId, connId, kind)
+		}
+		return nil
+	}
 	connConf := &ConnConf{
 		MessageType: f.conf.MessageType,
+		MaxMessageSize: f.conf.MaxMessageSize,
+		MaxFramesPerMessage: f.conf.MaxFramesPerMessage,
 		CBForRead: cbForRead,
 		CBForClosed: cbForClose,
+		CBForTooBig: cbForTooBig,
+		CBForLimited: cbForLimited,
+		Limiter: f.shared.Limiter,
+		Wheel: f.shared.Wheel,
+		IdleTimeout: f.conf.IdleTimeout,
+		IdleMode: f.conf.IdleMode,
+		PingInterval: f.conf.PingInterval,
+		Rpc: f.shared.Rpc,
+		JsonRpc: f.shared.JsonRpc,
+		Event: f.shared.Event,
+		PubSub: f.shared.GetPubSub(f.conf.EnablePubSub),
+		Reliable: f.shared.Reliable,
+		OrderedWrite: f.conf.OrderedWrite,
 	}
 
-	//init new connector
-	connector := NewConnector(connConf, connId, conn, timeouts...)
+	return NewConnector(connConf, connId, conn, timeouts...)
+}
 
-	//sync into bucket map with locker
-	f.Lock()
-	defer func() {
-		f.Unlock()
-		//check and call the connected cb of outside
-		if f.conf != nil && f.conf.CBForConnected != nil {
-			f.conf.CBForConnected(f, f.groupId, connector)
+//park connect lost by network, closed when grace window expired
+func (f *Group) parkConn(connId int64) bool {
+	if f.shared.Sessions == nil {
+		return false
+	}
+	connector, _ := f.GetConn(connId)
+	if connector == nil {
+		return false
+	}
+	return f.shared.Sessions.Park(connector, f.resumeConn, func() {
+		f.closeConn(connId)
+	})
+}
+
+//resume parked connector with new connect
+func (f *Group) resumeConn(old iface.IConnector, conn *websocket.Conn) (iface.IConnector, error) {
+	//init new connector with same conn id
+	connector := f.newConnector(old.GetConnId(), conn)
+	inheritConn(old, connector)
+
+	//replace parked connector
+	if err := f.ReplaceConn(connector); err != nil {
+		connector.CloseWithCode(define.CloseCodeGoingAway, "session closed")
+		return nil, err
+	}
+
+	//take over shared components and notify outside
+	f.shared.ResumeConn(old, connector)
+	if f.conf != nil && f.conf.CBForResumed != nil {
+		f.conf.CBForResumed(f, f.groupId, connector)
+	}
+	return connector, nil
+}
+
+//record untargeted cast into history
+//return nil if not recorded
+func (f *Group) recordHistory(data *gvar.MsgData) *gvar.HistoryMsg {
+	if f.shared.History == nil {
+		return nil
+	}
+	historyData := genHistoryData(data)
+	if historyData == nil {
+		return nil
+	}
+	historyMsg, err := f.shared.History.Append(f.groupId, historyData)
+	if err != nil {
+		log.Printf("group %v record history failed, err:%v\n", f.groupId, err)
+		return nil
+	}
+	return historyMsg
+}
+
+//wrap untargeted and unfiltered cast with seq of group
+//seq shared with history if enabled, zero means not recorded
+func (f *Group) stampSeq(data *gvar.MsgData, historyMsg *gvar.HistoryMsg) gvar.MsgData {
+	//check
+	if !f.conf.CastSeq {
+		return *data
+	}
+	if historyMsg == nil {
+		broadcastData := genBroadcastData(data)
+		if broadcastData == nil {
+			return *data
 		}
-	}()
-	f.connMap[connId] = connector
-	return nil
+		historyMsg = &gvar.HistoryMsg{
+			Group: f.groupId,
+			Time:  time.Now().UnixMilli(),
+			Data:  broadcastData,
+		}
+		if f.shared.History == nil {
+			f.castSeq++
+			historyMsg.Seq = f.castSeq
+		}
+	}
+
+	//replace data with json envelope
+	byteData, err := json.Marshal(&gvar.CastMsg{
+		Ctrl:       define.ControlOfCast,
+		HistoryMsg: *historyMsg,
+	})
+	if err != nil {
+		return *data
+	}
+	msgData := *data
+	if f.conf.MessageType == gvar.MessageTypeOfJson {
+		msgData.Data = json.RawMessage(byteData)
+		msgData.WriteInQueue = false
+	}else{
+		msgData.Data = byteData
+	}
+	return msgData
 }
 
-////////////////
-//private func
-////////////////
+//replay history messages as json text
+func (f *Group) replay(connector iface.IConnector, query *gvar.HistoryQuery) error {
+	//check
+	if f.shared.History == nil || connector == nil {
+		return nil
+	}
+	requested := query != nil
+	if query == nil {
+		if f.conf == nil || f.conf.HistoryReplay <= 0 {
+			return nil
+		}
+		query = &gvar.HistoryQuery{
+			Last: f.conf.HistoryReplay,
+		}
+	}
+
+	//query and write history
+	msgs, err := f.shared.History.Query(f.groupId, query)
+	if err != nil {
+		return err
+	}
+	if len(msgs) <= 0 {
+		if !requested {
+			return nil
+		}
+		msgs = []*gvar.HistoryMsg{}
+	}
+	reply := &gvar.HistoryReply{
+		Ctrl:  define.ControlOfHistory,
+		Group: f.groupId,
+		Msgs:  msgs,
+	}
+	return connector.Write(reply, gvar.MessageTypeOfJson)
+}
+
+//retire group if no connects and no adding one
+//return false if not empty, join after retired get errGroupRetired
+func (f *Group) retireIfEmpty() bool {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.connMap)+f.adding > 0 {
+		return false
+	}
+	f.connMap = nil
+	return true
+}
+
+//update membership and notify outside after leave
+func (f *Group) afterLeave(connId int64) {
+	if f.shared.Members != nil {
+		f.shared.Members.Remove(connId, f.groupId)
+	}
+	if f.conf != nil && f.conf.CBForLeave != nil {
+		f.conf.CBForLeave(f, f.groupId, connId)
+	}
+}
+
+//notify parent group is empty
+func (f *Group) notifyEmpty() {
+	if f.cbForEmpty != nil {
+		f.cbForEmpty(f)
+	}
+}
 
 //rebuild inter map
 func (f *Group) rebuild() {
 	//release old conn map
 	f.Lock()
 	defer f.Unlock()
+	if f.connMap == nil {
+		//group had quit
+		return
+	}
 	newConnMap := map[int64]iface.IConnector{}
 	for k, v := range f.connMap {
 		newConnMap[k] = v
@@ -323,8 +811,22 @@ func (f *Group) writeLoop() {
 			return errors.New("invalid parameter")
 		}
 
-		//check byte data
+		//check byte data and filter
 		byteData, _ := data.Data.([]byte)
+		filter := newCastFilter(data)
+		recorder := newDeliveryRecorder()
+		defer recorder.finish(data)
+
+		//sub func for write one connect
+		writeConn := func(connector iface.IConnector) {
+			var err error
+			if data.WriteInQueue {
+				err = connector.QueueWrite(byteData)
+			}else{
+				err = connector.Write(data.Data, f.conf.MessageType)
+			}
+			recorder.record(connector.GetConnId(), err)
+		}
 
 		f.Lock()
 		defer f.Unlock()
@@ -335,20 +837,16 @@ func (f *Group) writeLoop() {
 				if ownerId <= 0 {
 					continue
 				}
-				connId, ok := f.connOwnerMap[ownerId]
-				if !ok || connId <= 0 {
-					continue
-				}
-				v, ok := f.connMap[connId]
-				if ok && v != nil {
-					connector, sok := v.(iface.IConnector)
-					if sok && connector != nil {
+				//write to all connects of owner
+				for _, connId := range f.connOwnerMap.getConnIds(ownerId) {
+					connector, ok := f.connMap[connId]
+					if !ok || connector == nil {
+						continue
+					}
+					recorder.foundOwner(ownerId)
+					if filter.allow(connector) {
 						//write to target conn
-						if data.WriteInQueue {
-							connector.QueueWrite(byteData)
-						}else{
-							connector.Write(data.Data, f.conf.MessageType)
-						}
+						writeConn(connector)
 					}
 				}
 			}
@@ -361,32 +859,27 @@ func (f *Group) writeLoop() {
 				if connId <= 0 {
 					continue
 				}
-				v, ok := f.connMap[connId]
-				if ok && v != nil {
-					connector, sok := v.(iface.IConnector)
-					if sok && connector != nil {
-						//write to target conn
-						if data.WriteInQueue {
-							connector.QueueWrite(byteData)
-						}else{
-							connector.Write(data.Data, f.conf.MessageType)
-						}
-					}
+				connector, ok := f.connMap[connId]
+				if !ok || connector == nil {
+					continue
+				}
+				recorder.foundConn(connId)
+				if filter.allow(connector) {
+					//write to target conn
+					writeConn(connector)
 				}
 			}
+		}
+		if len(data.OwnerIds) > 0 || len(data.ConnIds) > 0 {
+			//only send to assigned owners or connects
 			return nil
 		}
 
 		//send to all connects
-		for _, v := range f.connMap {
-			connector, sok := v.(iface.IConnector)
-			if sok && connector != nil {
+		for _, connector := range f.connMap {
+			if connector != nil && filter.allow(connector) {
 				//write to target conn
-				if data.WriteInQueue {
-					connector.QueueWrite(byteData)
-				}else{
-					connector.Write(data.Data, f.conf.MessageType)
-				}
+				writeConn(connector)
 			}
 		}
 		return nil
@@ -0,0 +1,227 @@
+package face
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * in memory history store of groups
+ * - ring buffer by max count and age
+ */
+
+//history ring of one group
+type historyRing struct {
+	seq  int64
+	msgs []*gvar.HistoryMsg //sorted by seq
+}
+
+//face info
+type MemHistory struct {
+	maxMessages int
+	maxAge      time.Duration
+	ringMap     map[string]*historyRing //groupId -> ring
+	sync.RWMutex
+}
+
+//construct
+//zero maxAge means no age limit
+func NewMemHistory(maxMessages int, maxAge time.Duration) *MemHistory {
+	if maxMessages <= 0 {
+		maxMessages = define.DefaultHistoryMessages
+	}
+	this := &MemHistory{
+		maxMessages: maxMessages,
+		maxAge:      maxAge,
+		ringMap:     map[string]*historyRing{},
+	}
+	return this
+}
+
+//quit
+func (f *MemHistory) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	f.ringMap = map[string]*historyRing{}
+}
+
+//append message of group
+func (f *MemHistory) Append(groupId string, data json.RawMessage) (*gvar.HistoryMsg, error) {
+	//check
+	if groupId == "" || data == nil {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//append with locker
+	f.Lock()
+	defer f.Unlock()
+	ring, ok := f.ringMap[groupId]
+	if !ok {
+		ring = &historyRing{
+			msgs: []*gvar.HistoryMsg{},
+		}
+		f.ringMap[groupId] = ring
+	}
+	ring.seq++
+	msg := &gvar.HistoryMsg{
+		Group: groupId,
+		Seq:   ring.seq,
+		Time:  time.Now().UnixMilli(),
+		Data:  data,
+	}
+	ring.msgs = append(ring.msgs, msg)
+	f.prune(ring)
+	return msg, nil
+}
+
+//query messages of group
+func (f *MemHistory) Query(groupId string, query *gvar.HistoryQuery) ([]*gvar.HistoryMsg, error) {
+	//check
+	if groupId == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//query with locker
+	f.Lock()
+	defer f.Unlock()
+	ring, ok := f.ringMap[groupId]
+	if !ok {
+		return nil, nil
+	}
+	f.prune(ring)
+	return filterHistory(ring.msgs, query), nil
+}
+
+//remove all messages of group
+func (f *MemHistory) Remove(groupId string) error {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.ringMap, groupId)
+	return nil
+}
+
+//load messages of group, used by file history
+func (f *MemHistory) load(groupId string, msgs []*gvar.HistoryMsg) {
+	ring := &historyRing{
+		msgs: msgs,
+	}
+	if len(msgs) > 0 {
+		ring.seq = msgs[len(msgs)-1].Seq
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.prune(ring)
+	f.ringMap[groupId] = ring
+}
+
+//get all messages of group, used by file history
+func (f *MemHistory) snapshot(groupId string) []*gvar.HistoryMsg {
+	f.RLock()
+	defer f.RUnlock()
+	ring, ok := f.ringMap[groupId]
+	if !ok {
+		return nil
+	}
+	result := make([]*gvar.HistoryMsg, len(ring.msgs))
+	copy(result, ring.msgs)
+	return result
+}
+
+//drop messages over max count or age without locker
+func (f *MemHistory) prune(ring *historyRing) {
+	start := 0
+	if len(ring.msgs) > f.maxMessages {
+		start = len(ring.msgs) - f.maxMessages
+	}
+	if f.maxAge > 0 {
+		minTime := time.Now().Add(-f.maxAge).UnixMilli()
+		for start < len(ring.msgs) && ring.msgs[start].Time < minTime {
+			start++
+		}
+	}
+	if start > 0 {
+		ring.msgs = append([]*gvar.HistoryMsg{}, ring.msgs[start:]...)
+	}
+}
+
+//filter messages by query
+func filterHistory(msgs []*gvar.HistoryMsg, query *gvar.HistoryQuery) []*gvar.HistoryMsg {
+	result := make([]*gvar.HistoryMsg, 0, len(msgs))
+	for _, v := range msgs {
+		if query != nil && query.SinceSeq > 0 && v.Seq <= query.SinceSeq {
+			continue
+		}
+		if query != nil && query.SinceTime > 0 && v.Time < query.SinceTime {
+			continue
+		}
+		result = append(result, v)
+	}
+	if query != nil && query.Last > 0 && len(result) > query.Last {
+		result = result[len(result)-query.Last:]
+	}
+	return result
+}
+
+//gen history query from query paras
+//return nil if not assigned
+func genHistoryQuery(queryParas url.Values) *gvar.HistoryQuery {
+	query := &gvar.HistoryQuery{}
+	query.Last, _ = strconv.Atoi(queryParas.Get(define.QueryParaOfHistoryLast))
+	query.SinceSeq, _ = strconv.ParseInt(queryParas.Get(define.QueryParaOfHistorySeq), 10, 64)
+	query.SinceTime, _ = strconv.ParseInt(queryParas.Get(define.QueryParaOfHistoryTime), 10, 64)
+	if query.Last <= 0 && query.SinceSeq <= 0 && query.SinceTime <= 0 {
+		return nil
+	}
+	return query
+}
+
+//replay requested history of group to connector
+//nil query means all kept messages
+func replayHistory(shared *Shared, groupObj iface.IGroup, connId int64, query *gvar.HistoryQuery) error {
+	if shared.History == nil {
+		return errors.New("group history not enabled")
+	}
+	if query == nil {
+		query = &gvar.HistoryQuery{}
+	}
+	return groupObj.Replay(connId, query)
+}
+
+//gen history data of cast
+//return nil if cast has target conditions
+func genHistoryData(data *gvar.MsgData) json.RawMessage {
+	if data == nil || data.SkipHistory {
+		return nil
+	}
+	return genBroadcastData(data)
+}
+
+//gen json data of untargeted and unfiltered cast
+//return nil if cast has target conditions
+func genBroadcastData(data *gvar.MsgData) json.RawMessage {
+	if data == nil || data.Data == nil {
+		return nil
+	}
+	if len(data.ConnIds) > 0 || len(data.OwnerIds) > 0 ||
+		len(data.PropMatches) > 0 || data.Filter != nil {
+		return nil
+	}
+	if v, ok := data.Data.(json.RawMessage); ok {
+		return v
+	}
+	byteData, err := json.Marshal(data.Data)
+	if err != nil {
+		return nil
+	}
+	return byteData
+}
@@ -0,0 +1,311 @@
+package face
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * json rpc 2.0 face
+ * - decode single, batch request and notification
+ * - dispatch to go handler with typed params by reflect
+ */
+
+//type of handler args and results
+var (
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+//json rpc request or notification
+type jsonRpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"` //nil means notification
+}
+
+//json rpc response
+type jsonRpcResponse struct {
+	JsonRpc string             `json:"jsonrpc"`
+	Result  *json.RawMessage   `json:"result,omitempty"`
+	Error   *gvar.JsonRpcError `json:"error,omitempty"`
+	Id      json.RawMessage    `json:"id"`
+}
+
+//registered handler
+type jsonRpcHandler struct {
+	fn        reflect.Value
+	paramType reflect.Type //nil means no params
+}
+
+//face info
+type JsonRpcRouter struct {
+	handlers map[string]*jsonRpcHandler //method -> handler
+	sync.RWMutex
+}
+
+//construct
+func NewJsonRpcRouter() *JsonRpcRouter {
+	this := &JsonRpcRouter{
+		handlers: map[string]*jsonRpcHandler{},
+	}
+	return this
+}
+
+//register method handler
+//handler format like `func(connector interface{}, params T) (R, error)`,
+//or `func(connector interface{}) (R, error)` without params
+func (f *JsonRpcRouter) Register(method string, handler interface{}) error {
+	//check
+	if method == "" || handler == nil {
+		return errors.New("invalid parameter")
+	}
+	fnType := reflect.TypeOf(handler)
+	if fnType.Kind() != reflect.Func ||
+		fnType.NumIn() < 1 || fnType.NumIn() > 2 || fnType.In(0) != interfaceType ||
+		fnType.NumOut() != 2 || !fnType.Out(1).Implements(errorType) {
+		return errors.New("invalid handler format")
+	}
+
+	//init handler
+	h := &jsonRpcHandler{
+		fn: reflect.ValueOf(handler),
+	}
+	if fnType.NumIn() == 2 {
+		h.paramType = fnType.In(1)
+	}
+
+	//register with locker
+	f.Lock()
+	defer f.Unlock()
+	f.handlers[method] = h
+	return nil
+}
+
+//serve one message
+//return response data, nil if no need reply
+//return false if it's response of client or not json rpc 2.0, not handled
+func (f *JsonRpcRouter) Serve(connector interface{}, data []byte) ([]byte, bool) {
+	data = bytes.TrimSpace(data)
+
+	//batch request, claimed if any json rpc 2.0 element
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, false
+		}
+		isBatch := false
+		for _, v := range batch {
+			if f.isJsonRpc(v) {
+				isBatch = true
+				break
+			}
+		}
+		if !isBatch {
+			return nil, false
+		}
+		responses := make([]json.RawMessage, 0)
+		for _, v := range batch {
+			if resp := f.serveOne(connector, v); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) <= 0 {
+			//all notifications
+			return nil, true
+		}
+		result, _ := json.Marshal(responses)
+		return result, true
+	}
+
+	//skip non json rpc 2.0 message and response of client
+	if !f.isJsonRpc(data) || f.isResponse(data) {
+		return nil, false
+	}
+	return f.serveOne(connector, data), true
+}
+
+//serve one request
+//return nil for notification
+func (f *JsonRpcRouter) serveOne(connector interface{}, data []byte) []byte {
+	var (
+		m any = nil
+	)
+	//decode request
+	req := &jsonRpcRequest{}
+	if err := json.Unmarshal(data, req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return f.genErrorResp(nil, gvar.JsonRpcCodeOfParseError, err.Error())
+		}
+		return f.genErrorResp(nil, gvar.JsonRpcCodeOfInvalidRequest, err.Error())
+	}
+	if req.JsonRpc != define.JsonRpcVersion || req.Method == "" {
+		return f.genErrorResp(req.Id, gvar.JsonRpcCodeOfInvalidRequest, "invalid request")
+	}
+
+	//get handler
+	f.RLock()
+	h, ok := f.handlers[req.Method]
+	f.RUnlock()
+	if !ok || h == nil {
+		if req.Id == nil {
+			//notification, no reply
+			return nil
+		}
+		return f.genErrorResp(req.Id, gvar.JsonRpcCodeOfMethodNotFound,
+			fmt.Sprintf("method %v not found", req.Method))
+	}
+
+	//decode params
+	args := []reflect.Value{reflect.ValueOf(&connector).Elem()}
+	if h.paramType != nil {
+		param, err := f.decodeParams(h.paramType, req.Params)
+		if err != nil {
+			if req.Id == nil {
+				return nil
+			}
+			return f.genErrorResp(req.Id, gvar.JsonRpcCodeOfInvalidParams, err.Error())
+		}
+		args = append(args, param)
+	}
+
+	//call handler
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if pErr := recover(); pErr != m {
+				log.Printf("json rpc method %v panic, err:%v\n", req.Method, pErr)
+				err = fmt.Errorf("method panic: %v", pErr)
+			}
+		}()
+		outs := h.fn.Call(args)
+		if errVal := outs[1].Interface(); errVal != nil {
+			return nil, errVal.(error)
+		}
+		return outs[0].Interface(), nil
+	}()
+	if req.Id == nil {
+		//notification, no reply
+		return nil
+	}
+	if err != nil {
+		return f.genHandlerErrorResp(req.Id, err)
+	}
+
+	//gen response
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return f.genErrorResp(req.Id, gvar.JsonRpcCodeOfInternalError, err.Error())
+	}
+	rawResult := json.RawMessage(resultData)
+	resp, _ := json.Marshal(&jsonRpcResponse{
+		JsonRpc: define.JsonRpcVersion,
+		Result:  &rawResult,
+		Id:      req.Id,
+	})
+	return resp
+}
+
+//decode params into handler param type
+func (f *JsonRpcRouter) decodeParams(paramType reflect.Type, params json.RawMessage) (reflect.Value, error) {
+	if paramType.Kind() == reflect.Ptr {
+		param := reflect.New(paramType.Elem())
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, param.Interface()); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		return param, nil
+	}
+	param := reflect.New(paramType)
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, param.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return param.Elem(), nil
+}
+
+//check message is response or not
+func (f *JsonRpcRouter) isResponse(data []byte) bool {
+	probe := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, hasMethod := probe["method"]
+	_, hasResult := probe["result"]
+	_, hasError := probe["error"]
+	return !hasMethod && (hasResult || hasError)
+}
+
+//check message has json rpc 2.0 version
+func (f *JsonRpcRouter) isJsonRpc(data []byte) bool {
+	probe := struct {
+		JsonRpc string `json:"jsonrpc"`
+	}{}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.JsonRpc == define.JsonRpcVersion
+}
+
+//gen response of handler error
+func (f *JsonRpcRouter) genHandlerErrorResp(id json.RawMessage, err error) []byte {
+	rpcErr, ok := err.(*gvar.JsonRpcError)
+	if !ok {
+		rpcErr = &gvar.JsonRpcError{
+			Code:    gvar.JsonRpcCodeOfInternalError,
+			Message: err.Error(),
+		}
+		if nativeErr, sok := err.(*gvar.RpcError); sok {
+			rpcErr.Code = nativeErr.Code
+			rpcErr.Message = nativeErr.Message
+		}
+	}
+	resp, _ := json.Marshal(&jsonRpcResponse{
+		JsonRpc: define.JsonRpcVersion,
+		Error:   rpcErr,
+		Id:      id,
+	})
+	return resp
+}
+
+//gen error response
+func (f *JsonRpcRouter) genErrorResp(id json.RawMessage, code int, message string) []byte {
+	return f.genHandlerErrorResp(id, &gvar.JsonRpcError{
+		Code:    code,
+		Message: message,
+	})
+}
+
+//gen json rpc notification data by message type
+func GenJsonRpcNotify(messageType int, method string, params interface{}) (interface{}, error) {
+	//check
+	if method == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//encode notification
+	req := &jsonRpcRequest{
+		JsonRpc: define.JsonRpcVersion,
+		Method:  method,
+	}
+	if params != nil {
+		paramsData, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Params = paramsData
+	}
+	return genJsonCastData(messageType, req)
+}
@@ -0,0 +1,204 @@
+package face
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/andyzhou/websocket/gvar"
+)
+
+//add params of test method
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+//gen router with test methods
+func newTestJsonRpcRouter(t *testing.T, notified *int) *JsonRpcRouter {
+	router := NewJsonRpcRouter()
+	handlers := map[string]interface{}{
+		"add": func(connector interface{}, params *addParams) (interface{}, error) {
+			return params.A + params.B, nil
+		},
+		"ping": func(connector interface{}) (interface{}, error) {
+			return "pong", nil
+		},
+		"notify": func(connector interface{}) (interface{}, error) {
+			*notified++
+			return nil, nil
+		},
+		"fail": func(connector interface{}) (interface{}, error) {
+			return nil, errors.New("failed")
+		},
+		"denied": func(connector interface{}) (interface{}, error) {
+			return nil, &gvar.JsonRpcError{Code: 403, Message: "denied"}
+		},
+		"panic": func(connector interface{}) (interface{}, error) {
+			panic("boom")
+		},
+	}
+	for method, handler := range handlers {
+		if err := router.Register(method, handler); err != nil {
+			t.Fatalf("register %v failed, err:%v", method, err)
+		}
+	}
+	return router
+}
+
+func TestJsonRpcRegister(t *testing.T) {
+	router := NewJsonRpcRouter()
+	cases := []struct {
+		handler interface{}
+		wantErr bool
+	}{
+		{func(connector interface{}) (interface{}, error) { return nil, nil }, false},
+		{func(connector interface{}, params addParams) (int, error) { return 0, nil }, false},
+		{nil, true},
+		{"not func", true},
+		{func() (interface{}, error) { return nil, nil }, true},
+		{func(connector string) (interface{}, error) { return nil, nil }, true},
+		{func(connector interface{}) interface{} { return nil }, true},
+		{func(connector interface{}) (interface{}, string) { return nil, "" }, true},
+	}
+	for idx, c := range cases {
+		err := router.Register("method", c.handler)
+		if (err != nil) != c.wantErr {
+			t.Errorf("case %v register err:%v, want err:%v", idx, err, c.wantErr)
+		}
+	}
+}
+
+func TestJsonRpcServe(t *testing.T) {
+	cases := []struct {
+		name      string
+		request   string
+		handled   bool
+		response  string //empty means no reply
+		errorCode int    //check error code only if not zero
+	}{
+		{"request", `{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":2},"id":1}`,
+			true, `{"jsonrpc":"2.0","result":3,"id":1}`, 0},
+		{"request without params", `{"jsonrpc":"2.0","method":"ping","id":"x"}`,
+			true, `{"jsonrpc":"2.0","result":"pong","id":"x"}`, 0},
+		{"notification", `{"jsonrpc":"2.0","method":"notify"}`,
+			true, "", 0},
+		{"failed notification", `{"jsonrpc":"2.0","method":"fail"}`,
+			true, "", 0},
+		{"method not found", `{"jsonrpc":"2.0","method":"none","id":1}`,
+			true, "", gvar.JsonRpcCodeOfMethodNotFound},
+		{"invalid params", `{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`,
+			true, "", gvar.JsonRpcCodeOfInvalidParams},
+		{"empty method", `{"jsonrpc":"2.0","id":1}`,
+			true, "", gvar.JsonRpcCodeOfInvalidRequest},
+		{"handler error", `{"jsonrpc":"2.0","method":"fail","id":1}`,
+			true, "", gvar.JsonRpcCodeOfInternalError},
+		{"handler json rpc error", `{"jsonrpc":"2.0","method":"denied","id":1}`,
+			true, "", 403},
+		{"handler panic", `{"jsonrpc":"2.0","method":"panic","id":1}`,
+			true, "", gvar.JsonRpcCodeOfInternalError},
+		{"notification method not found", `{"jsonrpc":"2.0","method":"none"}`,
+			true, "", 0},
+		{"notification invalid params", `{"jsonrpc":"2.0","method":"add","params":[1,2]}`,
+			true, "", 0},
+		{"batch parse error", `[{"jsonrpc":"2.0"`,
+			false, "", 0},
+		{"empty batch", `[]`,
+			false, "", 0},
+		{"app array", `[1,2]`,
+			false, "", 0},
+		{"app object array", `[{"method":"add"}]`,
+			false, "", 0},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"notify"}]`,
+			true, "", 0},
+		{"client response", `{"jsonrpc":"2.0","result":1,"id":1}`,
+			false, "", 0},
+		{"no version", `{"method":"add","id":1}`,
+			false, "", 0},
+		{"other version", `{"jsonrpc":"1.0","method":"add","id":1}`,
+			false, "", 0},
+		{"control message", `{"ctrl":"sub","topic":"a.b"}`,
+			false, "", 0},
+		{"plain text", `hello`,
+			false, "", 0},
+	}
+	for _, c := range cases {
+		notified := 0
+		router := newTestJsonRpcRouter(t, &notified)
+		resp, handled := router.Serve(nil, []byte(c.request))
+		if handled != c.handled {
+			t.Errorf("%v: handled:%v, want:%v", c.name, handled, c.handled)
+			continue
+		}
+		if c.errorCode != 0 {
+			result := &jsonRpcResponse{}
+			if err := json.Unmarshal(resp, result); err != nil || result.Error == nil {
+				t.Errorf("%v: response %s, want error code %v", c.name, resp, c.errorCode)
+				continue
+			}
+			if result.Error.Code != c.errorCode {
+				t.Errorf("%v: error code %v, want %v", c.name, result.Error.Code, c.errorCode)
+			}
+			continue
+		}
+		if string(resp) != c.response {
+			t.Errorf("%v: response %s, want %s", c.name, resp, c.response)
+		}
+	}
+}
+
+func TestJsonRpcServeBatch(t *testing.T) {
+	notified := 0
+	router := newTestJsonRpcRouter(t, &notified)
+	request := `[
+		{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":1},"id":1},
+		{"jsonrpc":"2.0","method":"notify"},
+		{"jsonrpc":"2.0","method":"none"},
+		{"jsonrpc":"2.0","method":"add","params":[1],"id":4},
+		{"jsonrpc":"2.0","method":"none","id":2},
+		{"foo":"bar"},
+		{"jsonrpc":"2.0","method":"ping","id":3}
+	]`
+	resp, handled := router.Serve(nil, []byte(request))
+	if !handled {
+		t.Fatal("batch not handled")
+	}
+	if notified != 1 {
+		t.Fatalf("notified %v times, want 1", notified)
+	}
+	var results []*jsonRpcResponse
+	if err := json.Unmarshal(resp, &results); err != nil {
+		t.Fatalf("decode batch response %s failed, err:%v", resp, err)
+	}
+
+	//notification has no reply
+	if len(results) != 5 {
+		t.Fatalf("got %v responses, want 5", len(results))
+	}
+	wants := []struct {
+		id        string
+		result    string
+		errorCode int
+	}{
+		{"1", "2", 0},
+		{"4", "", gvar.JsonRpcCodeOfInvalidParams},
+		{"2", "", gvar.JsonRpcCodeOfMethodNotFound},
+		{"", "", gvar.JsonRpcCodeOfInvalidRequest},
+		{"3", `"pong"`, 0},
+	}
+	for idx, want := range wants {
+		result := results[idx]
+		if id := string(result.Id); id != want.id && !(want.id == "" && id == "null") {
+			t.Errorf("response %v id %v, want %v", idx, id, want.id)
+		}
+		if want.errorCode != 0 {
+			if result.Error == nil || result.Error.Code != want.errorCode {
+				t.Errorf("response %v error %v, want code %v", idx, result.Error, want.errorCode)
+			}
+			continue
+		}
+		if result.Result == nil || string(*result.Result) != want.result {
+			t.Errorf("response %v result %v, want %v", idx, result.Result, want.result)
+		}
+	}
+}
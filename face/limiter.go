@@ -0,0 +1,240 @@
+package face
+
+import (
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * inbound rate limiter face
+ * - token bucket per connection, owner id and remote ip
+ * - owner and ip buckets shared by all connects of router or dynamic
+ */
+
+//token bucket
+type TokenBucket struct {
+	rate     float64 //tokens per second
+	burst    float64
+	tokens   float64
+	lastTime time.Time
+	sync.Mutex
+}
+
+//construct
+func NewTokenBucket(conf *gvar.RateLimitConf) *TokenBucket {
+	burst := float64(conf.Burst)
+	if burst <= 0 {
+		burst = conf.Rate
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	this := &TokenBucket{
+		rate:     conf.Rate,
+		burst:    burst,
+		tokens:   burst,
+		lastTime: time.Now(),
+	}
+	return this
+}
+
+//take one token if available
+func (f *TokenBucket) Allow() bool {
+	f.Lock()
+	defer f.Unlock()
+	f.refill()
+	if f.tokens < 1 {
+		return false
+	}
+	f.tokens--
+	return true
+}
+
+//reserve one token
+//return the duration need to wait
+func (f *TokenBucket) Reserve() time.Duration {
+	f.Lock()
+	defer f.Unlock()
+	f.refill()
+	f.tokens--
+	if f.tokens >= 0 || f.rate <= 0 {
+		return 0
+	}
+	return time.Duration(-f.tokens / f.rate * float64(time.Second))
+}
+
+//give back one taken token
+func (f *TokenBucket) Refund() {
+	f.Lock()
+	defer f.Unlock()
+	f.tokens++
+	if f.tokens > f.burst {
+		f.tokens = f.burst
+	}
+}
+
+//check bucket is full and idle
+func (f *TokenBucket) isIdle() bool {
+	f.Lock()
+	defer f.Unlock()
+	f.refill()
+	return f.tokens >= f.burst
+}
+
+//refill tokens by elapsed time
+func (f *TokenBucket) refill() {
+	now := time.Now()
+	f.tokens += now.Sub(f.lastTime).Seconds() * f.rate
+	if f.tokens > f.burst {
+		f.tokens = f.burst
+	}
+	f.lastTime = now
+}
+
+//face info
+type RateLimiter struct {
+	connConf  *gvar.RateLimitConf
+	ownerConf *gvar.RateLimitConf
+	ipConf    *gvar.RateLimitConf
+	ownerMap  map[int64]*TokenBucket  //ownerId -> bucket
+	ipMap     map[string]*TokenBucket //ip -> bucket
+	closeChan chan bool
+	closeOnce sync.Once
+	locker    sync.Mutex
+}
+
+//construct
+//return nil if no limit config
+func NewRateLimiter(connConf, ownerConf, ipConf *gvar.RateLimitConf) *RateLimiter {
+	if connConf == nil && ownerConf == nil && ipConf == nil {
+		return nil
+	}
+	this := &RateLimiter{
+		connConf:  connConf,
+		ownerConf: ownerConf,
+		ipConf:    ipConf,
+		ownerMap:  map[int64]*TokenBucket{},
+		ipMap:     map[string]*TokenBucket{},
+		closeChan: make(chan bool, 1),
+	}
+	go this.cleanLoop()
+	return this
+}
+
+//quit
+func (f *RateLimiter) Quit() {
+	f.closeOnce.Do(func() {
+		close(f.closeChan)
+	})
+}
+
+//gen token bucket for new connect
+func (f *RateLimiter) NewConnBucket() *TokenBucket {
+	if f.connConf == nil {
+		return nil
+	}
+	return NewTokenBucket(f.connConf)
+}
+
+//check one inbound message
+//return triggered kind, policy and wait duration, kind -1 means passed
+func (f *RateLimiter) Check(connBucket *TokenBucket, ownerId int64, ip string) (int, int, time.Duration) {
+	var (
+		maxWait time.Duration
+		kind = -1
+		policy int
+	)
+	//check by kind, slice idx same as gvar.RateLimitOfXXX
+	//taken tokens refunded if denied by later bucket
+	buckets := []*TokenBucket{connBucket, f.getOwnerBucket(ownerId), f.getIpBucket(ip)}
+	confs := []*gvar.RateLimitConf{f.connConf, f.ownerConf, f.ipConf}
+	for idx, bucket := range buckets {
+		if bucket == nil {
+			continue
+		}
+		conf := confs[idx]
+		if conf.Policy != gvar.RateLimitPolicyOfDelay {
+			if !bucket.Allow() {
+				for _, taken := range buckets[:idx] {
+					if taken != nil {
+						taken.Refund()
+					}
+				}
+				return idx, conf.Policy, 0
+			}
+			continue
+		}
+		wait := bucket.Reserve()
+		if wait > maxWait {
+			maxWait = wait
+			kind = idx
+			policy = conf.Policy
+		}
+	}
+	return kind, policy, maxWait
+}
+
+//get or create owner bucket
+func (f *RateLimiter) getOwnerBucket(ownerId int64) *TokenBucket {
+	if f.ownerConf == nil || ownerId <= 0 {
+		return nil
+	}
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	bucket, ok := f.ownerMap[ownerId]
+	if !ok {
+		bucket = NewTokenBucket(f.ownerConf)
+		f.ownerMap[ownerId] = bucket
+	}
+	return bucket
+}
+
+//get or create ip bucket
+func (f *RateLimiter) getIpBucket(ip string) *TokenBucket {
+	if f.ipConf == nil || ip == "" {
+		return nil
+	}
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	bucket, ok := f.ipMap[ip]
+	if !ok {
+		bucket = NewTokenBucket(f.ipConf)
+		f.ipMap[ip] = bucket
+	}
+	return bucket
+}
+
+//clean idle owner and ip buckets
+func (f *RateLimiter) clean() {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	for k, v := range f.ownerMap {
+		if v.isIdle() {
+			delete(f.ownerMap, k)
+		}
+	}
+	for k, v := range f.ipMap {
+		if v.isIdle() {
+			delete(f.ipMap, k)
+		}
+	}
+}
+
+//clean loop
+func (f *RateLimiter) cleanLoop() {
+	ticker := time.NewTicker(define.LimiterCleanSeconds * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.clean()
+		case <-f.closeChan:
+			return
+		}
+	}
+}
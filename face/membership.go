@@ -0,0 +1,68 @@
+package face
+
+import (
+	"sync"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * group membership face
+ * - one connect join multi groups
+ * - shared by dynamic and all groups
+ */
+
+//face info
+type Membership struct {
+	connMap map[int64]map[string]bool //connId -> groupIds
+	sync.RWMutex
+}
+
+//construct
+func NewMembership() *Membership {
+	this := &Membership{
+		connMap: map[int64]map[string]bool{},
+	}
+	return this
+}
+
+//add group of connect
+func (f *Membership) Add(connId int64, groupId string) {
+	f.Lock()
+	defer f.Unlock()
+	groupIds, ok := f.connMap[connId]
+	if !ok {
+		groupIds = map[string]bool{}
+		f.connMap[connId] = groupIds
+	}
+	groupIds[groupId] = true
+}
+
+//remove group of connect
+func (f *Membership) Remove(connId int64, groupId string) {
+	f.Lock()
+	defer f.Unlock()
+	groupIds, ok := f.connMap[connId]
+	if !ok {
+		return
+	}
+	delete(groupIds, groupId)
+	if len(groupIds) <= 0 {
+		delete(f.connMap, connId)
+	}
+}
+
+//get all groups of connect
+func (f *Membership) Get(connId int64) []string {
+	f.RLock()
+	defer f.RUnlock()
+	groupIds, ok := f.connMap[connId]
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(groupIds))
+	for k := range groupIds {
+		result = append(result, k)
+	}
+	return result
+}
@@ -0,0 +1,246 @@
+package face
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * in memory offline message store
+ * - kept per owner, limited by count and ttl
+ */
+
+//face info
+type MemOffline struct {
+	maxMessages int
+	ttl         time.Duration
+	ownerMap    map[int64][]*gvar.OfflineMsg //ownerId -> messages
+	total       int                          //kept messages of all owners, may include expired
+	wheel       *TimingWheel                 //expire messages, nil means never
+	sync.Mutex
+}
+
+//construct
+//zero ttl means never expired
+func NewMemOffline(maxMessages int, ttl time.Duration) *MemOffline {
+	if maxMessages <= 0 {
+		maxMessages = define.DefaultOfflineMessages
+	}
+	this := &MemOffline{
+		maxMessages: maxMessages,
+		ttl:         ttl,
+		ownerMap:    map[int64][]*gvar.OfflineMsg{},
+	}
+	if ttl > 0 {
+		this.wheel = NewTimingWheel(define.IdleWheelTick, define.IdleWheelSlots)
+	}
+	return this
+}
+
+//quit
+func (f *MemOffline) Quit() {
+	if f.wheel != nil {
+		f.wheel.Quit()
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.ownerMap = map[int64][]*gvar.OfflineMsg{}
+	f.total = 0
+}
+
+//push message of owner, drop the oldest when full
+func (f *MemOffline) Push(ownerId int64, msg *gvar.OfflineMsg) error {
+	//check
+	if ownerId <= 0 || msg == nil {
+		return errors.New("invalid parameter")
+	}
+	if msg.Time <= 0 {
+		msg.Time = time.Now().UnixMilli()
+	}
+
+	//push with locker
+	f.Lock()
+	defer f.Unlock()
+	msgs := f.prune(f.ownerMap[ownerId])
+	if len(msgs) <= 0 && f.wheel != nil {
+		f.wheel.Add(ownerId, f.ttl, func() {
+			f.expire(ownerId)
+		})
+	}
+	msgs = append(msgs, msg)
+	if len(msgs) > f.maxMessages {
+		msgs = msgs[len(msgs)-f.maxMessages:]
+	}
+	f.setMsgs(ownerId, msgs)
+	return nil
+}
+
+//push messages of owner before kept messages
+//used to push back undelivered messages, drop the oldest when full
+func (f *MemOffline) PushFront(ownerId int64, msgs []*gvar.OfflineMsg) error {
+	//check
+	if ownerId <= 0 {
+		return errors.New("invalid parameter")
+	}
+
+	//push with locker
+	f.Lock()
+	defer f.Unlock()
+	front := f.prune(msgs)
+	if len(front) <= 0 {
+		return nil
+	}
+	kept := f.prune(f.ownerMap[ownerId])
+	result := make([]*gvar.OfflineMsg, 0, len(front)+len(kept))
+	result = append(append(result, front...), kept...)
+	if len(result) > f.maxMessages {
+		result = result[len(result)-f.maxMessages:]
+	}
+	f.setMsgs(ownerId, result)
+	if f.wheel != nil {
+		//the oldest message changed, reset expire time
+		delay := time.Until(time.UnixMilli(result[0].Time).Add(f.ttl))
+		f.wheel.Add(ownerId, delay, func() {
+			f.expire(ownerId)
+		})
+	}
+	return nil
+}
+
+//take all messages of owner
+func (f *MemOffline) Take(ownerId int64) ([]*gvar.OfflineMsg, error) {
+	f.Lock()
+	defer f.Unlock()
+	msgs, ok := f.ownerMap[ownerId]
+	if !ok {
+		return nil, nil
+	}
+	f.setMsgs(ownerId, nil)
+	if f.wheel != nil {
+		f.wheel.Remove(ownerId)
+	}
+	return f.prune(msgs), nil
+}
+
+//get total messages of owner
+func (f *MemOffline) GetTotal(ownerId int64) int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.prune(f.ownerMap[ownerId]))
+}
+
+//get total messages of all owners, used by file offline
+func (f *MemOffline) getTotalAll() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.total
+}
+
+//get copy of all messages, used by file offline
+func (f *MemOffline) snapshot() map[int64][]*gvar.OfflineMsg {
+	f.Lock()
+	defer f.Unlock()
+	result := make(map[int64][]*gvar.OfflineMsg, len(f.ownerMap))
+	for k, v := range f.ownerMap {
+		msgs := f.prune(v)
+		if len(msgs) > 0 {
+			result[k] = msgs
+		}
+	}
+	return result
+}
+
+//remove expired messages of owner and wait next expire
+func (f *MemOffline) expire(ownerId int64) {
+	f.Lock()
+	defer f.Unlock()
+	msgs := f.prune(f.ownerMap[ownerId])
+	f.setMsgs(ownerId, msgs)
+	if len(msgs) <= 0 {
+		return
+	}
+	delay := time.Until(time.UnixMilli(msgs[0].Time).Add(f.ttl))
+	f.wheel.Add(ownerId, delay, func() {
+		f.expire(ownerId)
+	})
+}
+
+//set messages of owner and update total without locker
+func (f *MemOffline) setMsgs(ownerId int64, msgs []*gvar.OfflineMsg) {
+	f.total -= len(f.ownerMap[ownerId])
+	if len(msgs) <= 0 {
+		delete(f.ownerMap, ownerId)
+		return
+	}
+	f.ownerMap[ownerId] = msgs
+	f.total += len(msgs)
+}
+
+//drop expired messages without locker
+func (f *MemOffline) prune(msgs []*gvar.OfflineMsg) []*gvar.OfflineMsg {
+	if f.ttl <= 0 {
+		return msgs
+	}
+	minTime := time.Now().Add(-f.ttl).UnixMilli()
+	idx := 0
+	for idx < len(msgs) && msgs[idx].Time < minTime {
+		idx++
+	}
+	return msgs[idx:]
+}
+
+//gen offline message of cast
+//return nil if data can't be kept
+func genOfflineMsg(data *gvar.MsgData, messageType int) *gvar.OfflineMsg {
+	msg := &gvar.OfflineMsg{
+		MessageType: messageType,
+		InQueue:     data.WriteInQueue,
+		Time:        time.Now().UnixMilli(),
+	}
+	switch v := data.Data.(type) {
+	case []byte:
+		if messageType == gvar.MessageTypeOfJson && !data.WriteInQueue {
+			msg.Data, _ = json.Marshal(v)
+		}else{
+			msg.Data = v
+		}
+	case string:
+		if data.WriteInQueue {
+			return nil
+		}
+		if messageType == gvar.MessageTypeOfJson {
+			msg.Data, _ = json.Marshal(v)
+		}else{
+			msg.Data = []byte(v)
+		}
+	default:
+		if messageType != gvar.MessageTypeOfJson || data.WriteInQueue {
+			return nil
+		}
+		byteData, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		msg.Data = byteData
+	}
+	return msg
+}
+
+//write offline message to connector
+func writeOfflineMsg(connector iface.IConnector, msg *gvar.OfflineMsg) error {
+	if msg.InQueue {
+		return connector.QueueWrite(msg.Data, msg.DirectWrite)
+	}
+	if msg.MessageType == gvar.MessageTypeOfJson {
+		return connector.Write(json.RawMessage(msg.Data), gvar.MessageTypeOfJson)
+	}
+	return connector.Write(msg.Data, msg.MessageType)
+}
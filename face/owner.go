@@ -0,0 +1,119 @@
+package face
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * owner index face
+ * - one owner id, multi connects
+ * - not thread safe, should be used with locker of bucket or group
+ * - owner locker serialize policy check and bind of same owner
+ */
+
+//owner id -> conn id -> bind time
+type ownerIndex map[int64]map[int64]int64
+
+//striped locker of owners
+type ownerLocker struct {
+	lockers [define.OwnerLockerSlots]sync.Mutex
+}
+
+//lock owner, return unlock func
+func (f *ownerLocker) lock(ownerId int64) func() {
+	locker := &f.lockers[uint64(ownerId)%define.OwnerLockerSlots]
+	locker.Lock()
+	return locker.Unlock
+}
+
+//bind connect to owner
+func (m ownerIndex) add(ownerId, connId, bindTime int64) {
+	if ownerId <= 0 || connId <= 0 {
+		return
+	}
+	connIds, ok := m[ownerId]
+	if !ok {
+		connIds = map[int64]int64{}
+		m[ownerId] = connIds
+	}
+	connIds[connId] = bindTime
+}
+
+//unbind connect from owner
+func (m ownerIndex) remove(ownerId, connId int64) {
+	connIds, ok := m[ownerId]
+	if !ok {
+		return
+	}
+	delete(connIds, connId)
+	if len(connIds) <= 0 {
+		delete(m, ownerId)
+	}
+}
+
+//get conn ids of owner, newest first
+func (m ownerIndex) getConnIds(ownerId int64) []int64 {
+	connIds, ok := m[ownerId]
+	if !ok || len(connIds) <= 0 {
+		return nil
+	}
+	result := make([]int64, 0, len(connIds))
+	for connId := range connIds {
+		result = append(result, connId)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return connIds[result[i]] > connIds[result[j]]
+	})
+	return result
+}
+
+//copy into new index
+func (m ownerIndex) clone() ownerIndex {
+	newIndex := ownerIndex{}
+	for ownerId, connIds := range m {
+		newConnIds := make(map[int64]int64, len(connIds))
+		for k, v := range connIds {
+			newConnIds[k] = v
+		}
+		newIndex[ownerId] = newConnIds
+	}
+	return newIndex
+}
+
+//check owner policy before bind new connect
+//return old connects which should be kicked, or error if new connect rejected
+func checkOwnerPolicy(policy int, connId int64, olds []iface.IConnector) ([]iface.IConnector, error) {
+	kicks := make([]iface.IConnector, 0)
+	for _, old := range olds {
+		if old == nil || old.GetConnId() == connId {
+			continue
+		}
+		kicks = append(kicks, old)
+	}
+	if len(kicks) <= 0 {
+		return nil, nil
+	}
+	switch policy {
+	case gvar.OwnerPolicyOfKickOld:
+		return kicks, nil
+	case gvar.OwnerPolicyOfRejectNew:
+		return nil, errors.New("owner already connected")
+	default:
+		return nil, nil
+	}
+}
+
+//kick old connects of owner
+func kickOwnerConns(kicks []iface.IConnector) {
+	for _, v := range kicks {
+		v.CloseWithCode(define.CloseCodePolicyViolation, "replaced by new connect")
+	}
+}
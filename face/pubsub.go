@@ -0,0 +1,345 @@
+package face
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * topic pub/sub face
+ * - server wide, cross routers and dynamic groups
+ * - hierarchical topic with `*` and `>` wildcards
+ */
+
+//topic trie node
+type topicNode struct {
+	children map[string]*topicNode
+	subs     map[iface.IConnector]bool
+}
+
+//face info
+type PubSub struct {
+	root       *topicNode
+	connTopics map[iface.IConnector]map[string]bool //connector -> patterns
+	sync.RWMutex
+}
+
+//construct
+func NewPubSub() *PubSub {
+	this := &PubSub{
+		root:       newTopicNode(),
+		connTopics: map[iface.IConnector]map[string]bool{},
+	}
+	return this
+}
+
+//subscribe topic pattern
+func (f *PubSub) Subscribe(connector iface.IConnector, pattern string) error {
+	//check
+	if connector == nil {
+		return errors.New("invalid parameter")
+	}
+	tokens, err := splitTopic(pattern, true)
+	if err != nil {
+		return err
+	}
+
+	//add into trie with locker
+	f.Lock()
+	defer f.Unlock()
+	node := f.root
+	for _, token := range tokens {
+		child, ok := node.children[token]
+		if !ok {
+			child = newTopicNode()
+			node.children[token] = child
+		}
+		node = child
+	}
+	node.subs[connector] = true
+	patterns, ok := f.connTopics[connector]
+	if !ok {
+		patterns = map[string]bool{}
+		f.connTopics[connector] = patterns
+	}
+	patterns[pattern] = true
+	return nil
+}
+
+//unsubscribe topic pattern
+func (f *PubSub) Unsubscribe(connector iface.IConnector, pattern string) error {
+	//check
+	if connector == nil {
+		return errors.New("invalid parameter")
+	}
+	tokens, err := splitTopic(pattern, true)
+	if err != nil {
+		return err
+	}
+
+	//remove with locker
+	f.Lock()
+	defer f.Unlock()
+	patterns, ok := f.connTopics[connector]
+	if !ok || !patterns[pattern] {
+		return errors.New("topic not subscribed")
+	}
+	delete(patterns, pattern)
+	if len(patterns) <= 0 {
+		delete(f.connTopics, connector)
+	}
+	f.remove(f.root, tokens, connector)
+	return nil
+}
+
+//unsubscribe all topics of connector
+func (f *PubSub) UnsubscribeAll(connector iface.IConnector) {
+	if connector == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	patterns, ok := f.connTopics[connector]
+	if !ok {
+		return
+	}
+	delete(f.connTopics, connector)
+	for pattern := range patterns {
+		tokens, _ := splitTopic(pattern, true)
+		f.remove(f.root, tokens, connector)
+	}
+}
+
+//move all subscriptions to new connector
+func (f *PubSub) Transfer(old, connector iface.IConnector) {
+	if old == nil || connector == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	patterns, ok := f.connTopics[old]
+	if !ok {
+		return
+	}
+	delete(f.connTopics, old)
+	f.connTopics[connector] = patterns
+	for pattern := range patterns {
+		tokens, _ := splitTopic(pattern, true)
+		node := f.root
+		for _, token := range tokens {
+			node = node.children[token]
+			if node == nil {
+				break
+			}
+		}
+		if node != nil {
+			delete(node.subs, old)
+			node.subs[connector] = true
+		}
+	}
+}
+
+//get subscribed topic patterns of connector
+func (f *PubSub) GetTopics(connector iface.IConnector) []string {
+	f.RLock()
+	defer f.RUnlock()
+	patterns := f.connTopics[connector]
+	result := make([]string, 0, len(patterns))
+	for pattern := range patterns {
+		result = append(result, pattern)
+	}
+	return result
+}
+
+//get matched connectors of topic
+func (f *PubSub) Match(topic string) ([]iface.IConnector, error) {
+	tokens, err := splitTopic(topic, false)
+	if err != nil {
+		return nil, err
+	}
+	matched := map[iface.IConnector]bool{}
+	f.RLock()
+	f.match(f.root, tokens, matched)
+	f.RUnlock()
+	result := make([]iface.IConnector, 0, len(matched))
+	for connector := range matched {
+		result = append(result, connector)
+	}
+	return result, nil
+}
+
+//publish data to matched connectors
+//data will be json encoded, use json.RawMessage for encoded data
+//return delivered connectors count
+func (f *PubSub) Publish(topic string, data interface{}) (int, error) {
+	//get matched connectors
+	connectors, err := f.Match(topic)
+	if err != nil {
+		return 0, err
+	}
+	if len(connectors) <= 0 {
+		return 0, nil
+	}
+
+	//encode message
+	msg := &gvar.PubMsg{
+		Topic: topic,
+	}
+	if data != nil {
+		msg.Data, err = json.Marshal(data)
+		if err != nil {
+			return 0, err
+		}
+	}
+	byteData, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	//write to all matched connectors
+	delivered := 0
+	for _, connector := range connectors {
+		if connector.Write(json.RawMessage(byteData), gvar.MessageTypeOfJson) == nil {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+//handle sub or unsub control message of connector
+//return true if it's pub/sub control message
+func (f *PubSub) HandleControl(connector iface.IConnector, data interface{}) bool {
+	ctrl := parsePubSubControl(data)
+	if ctrl == nil {
+		return false
+	}
+
+	//run control opt
+	var err error
+	reply := &gvar.ControlMsg{
+		Topic: ctrl.Topic,
+	}
+	switch ctrl.Ctrl {
+	case define.ControlOfSub:
+		err = f.Subscribe(connector, ctrl.Topic)
+		reply.Ctrl = define.ControlOfSubbed
+	case define.ControlOfUnsub:
+		err = f.Unsubscribe(connector, ctrl.Topic)
+		reply.Ctrl = define.ControlOfUnsubbed
+	}
+	if err != nil {
+		reply.Ctrl = define.ControlOfError
+		reply.Reason = err.Error()
+	}
+	connector.Write(reply, gvar.MessageTypeOfJson)
+	return true
+}
+
+//remove connector from trie and prune empty nodes
+func (f *PubSub) remove(node *topicNode, tokens []string, connector iface.IConnector) {
+	if len(tokens) <= 0 {
+		delete(node.subs, connector)
+		return
+	}
+	child, ok := node.children[tokens[0]]
+	if !ok {
+		return
+	}
+	f.remove(child, tokens[1:], connector)
+	if len(child.subs) <= 0 && len(child.children) <= 0 {
+		delete(node.children, tokens[0])
+	}
+}
+
+//collect matched subscribers
+func (f *PubSub) match(node *topicNode, tokens []string, matched map[iface.IConnector]bool) {
+	if len(tokens) <= 0 {
+		for connector := range node.subs {
+			matched[connector] = true
+		}
+		return
+	}
+	if child, ok := node.children[define.TopicMultiWildcard]; ok {
+		for connector := range child.subs {
+			matched[connector] = true
+		}
+	}
+	if child, ok := node.children[define.TopicSingleWildcard]; ok {
+		f.match(child, tokens[1:], matched)
+	}
+	if child, ok := node.children[tokens[0]]; ok {
+		f.match(child, tokens[1:], matched)
+	}
+}
+
+//gen new trie node
+func newTopicNode() *topicNode {
+	return &topicNode{
+		children: map[string]*topicNode{},
+		subs:     map[iface.IConnector]bool{},
+	}
+}
+
+//split and verify topic or pattern
+func splitTopic(topic string, isPattern bool) ([]string, error) {
+	if topic == "" {
+		return nil, errors.New("empty topic")
+	}
+	tokens := strings.Split(topic, define.TopicSeparator)
+	for idx, token := range tokens {
+		if token == "" {
+			return nil, fmt.Errorf("invalid topic %v", topic)
+		}
+		if token != define.TopicSingleWildcard && token != define.TopicMultiWildcard {
+			continue
+		}
+		if !isPattern {
+			return nil, fmt.Errorf("wildcard not allowed in topic %v", topic)
+		}
+		if token == define.TopicMultiWildcard && idx != len(tokens)-1 {
+			return nil, fmt.Errorf("`%v` should be the last token of %v", define.TopicMultiWildcard, topic)
+		}
+	}
+	return tokens, nil
+}
+
+//parse pub/sub control message
+//return nil if not pub/sub control message
+func parsePubSubControl(data interface{}) *gvar.ControlMsg {
+	var (
+		byteData []byte
+	)
+	switch v := data.(type) {
+	case []byte:
+		byteData = v
+	case string:
+		byteData = []byte(v)
+	case map[string]interface{}:
+		if _, ok := v["ctrl"]; !ok {
+			return nil
+		}
+		byteData, _ = json.Marshal(v)
+	default:
+		return nil
+	}
+	if len(byteData) <= 0 || byteData[0] != '{' {
+		return nil
+	}
+	ctrl := &gvar.ControlMsg{}
+	if err := json.Unmarshal(byteData, ctrl); err != nil {
+		return nil
+	}
+	if ctrl.Ctrl != define.ControlOfSub && ctrl.Ctrl != define.ControlOfUnsub {
+		return nil
+	}
+	return ctrl
+}
@@ -0,0 +1,112 @@
+package face
+
+import (
+	"testing"
+
+	"github.com/andyzhou/websocket/iface"
+)
+
+//fake connector, only used as subscriber key
+type fakeConnector struct {
+	iface.IConnector
+	name string
+}
+
+func TestSplitTopic(t *testing.T) {
+	cases := []struct {
+		topic     string
+		isPattern bool
+		wantErr   bool
+	}{
+		{"a.b.c", false, false},
+		{"a.*.c", true, false},
+		{"a.>", true, false},
+		{">", true, false},
+		{"", false, true},
+		{"a..c", false, true},
+		{"a.b.", true, true},
+		{"a.*", false, true},
+		{"a.>", false, true},
+		{"a.>.c", true, true},
+		{">.a", true, true},
+	}
+	for _, c := range cases {
+		_, err := splitTopic(c.topic, c.isPattern)
+		if (err != nil) != c.wantErr {
+			t.Errorf("splitTopic(%q, %v) err:%v, want err:%v", c.topic, c.isPattern, err, c.wantErr)
+		}
+	}
+}
+
+func TestPubSubMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		topic   string
+		matched bool
+	}{
+		{"a.b.c", "a.b.c", true},
+		{"a.b.c", "a.b", false},
+		{"a.b.c", "a.b.c.d", false},
+		{"a.*.c", "a.b.c", true},
+		{"a.*.c", "a.x.c", true},
+		{"a.*.c", "a.b.d", false},
+		{"a.*.c", "a.c", false},
+		{"a.*", "a.b", true},
+		{"a.*", "a.b.c", false},
+		{"*", "a", true},
+		{"*", "a.b", false},
+		{"a.>", "a.b", true},
+		{"a.>", "a.b.c.d", true},
+		{"a.>", "a", false},
+		{"a.>", "b.c", false},
+		{">", "a", true},
+		{">", "a.b.c", true},
+		{"*.b.>", "a.b.c", true},
+		{"*.b.>", "a.b", false},
+		{"*.b.>", "a.x.c", false},
+	}
+	for _, c := range cases {
+		pubSub := NewPubSub()
+		connector := &fakeConnector{name: c.pattern}
+		if err := pubSub.Subscribe(connector, c.pattern); err != nil {
+			t.Fatalf("subscribe %q failed, err:%v", c.pattern, err)
+		}
+		connectors, err := pubSub.Match(c.topic)
+		if err != nil {
+			t.Fatalf("match %q failed, err:%v", c.topic, err)
+		}
+		if got := len(connectors) == 1; got != c.matched {
+			t.Errorf("pattern %q topic %q matched:%v, want:%v", c.pattern, c.topic, got, c.matched)
+		}
+	}
+}
+
+func TestPubSubMatchDedupAndUnsubscribe(t *testing.T) {
+	pubSub := NewPubSub()
+	first := &fakeConnector{name: "first"}
+	second := &fakeConnector{name: "second"}
+	for _, pattern := range []string{"a.b", "a.*", "a.>"} {
+		if err := pubSub.Subscribe(first, pattern); err != nil {
+			t.Fatalf("subscribe %q failed, err:%v", pattern, err)
+		}
+	}
+	if err := pubSub.Subscribe(second, "a.b"); err != nil {
+		t.Fatalf("subscribe failed, err:%v", err)
+	}
+
+	//one connector matched by several patterns counts once
+	connectors, _ := pubSub.Match("a.b")
+	if len(connectors) != 2 {
+		t.Fatalf("matched %v connectors, want 2", len(connectors))
+	}
+
+	//unsubscribe all patterns of first
+	pubSub.UnsubscribeAll(first)
+	connectors, _ = pubSub.Match("a.b")
+	if len(connectors) != 1 || connectors[0] != iface.IConnector(second) {
+		t.Fatalf("matched %v after unsubscribe, want only second", connectors)
+	}
+	if topics := pubSub.GetTopics(first); len(topics) != 0 {
+		t.Fatalf("topics of first %v, want empty", topics)
+	}
+}
@@ -0,0 +1,90 @@
+package face
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * server wide owner registry face
+ * - track all connects of owner cross routers and dynamic groups
+ */
+
+//face info
+type OwnerRegistry struct {
+	ownerMap map[int64]map[iface.IConnector]bool //ownerId -> connectors
+	sync.RWMutex
+}
+
+//construct
+func NewOwnerRegistry() *OwnerRegistry {
+	this := &OwnerRegistry{
+		ownerMap: map[int64]map[iface.IConnector]bool{},
+	}
+	return this
+}
+
+//add connector of owner
+func (f *OwnerRegistry) Add(ownerId int64, connector iface.IConnector) error {
+	//check
+	if ownerId <= 0 || connector == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//add with locker
+	f.Lock()
+	defer f.Unlock()
+	connectors, ok := f.ownerMap[ownerId]
+	if !ok {
+		connectors = map[iface.IConnector]bool{}
+		f.ownerMap[ownerId] = connectors
+	}
+	connectors[connector] = true
+	return nil
+}
+
+//remove connector of owner
+func (f *OwnerRegistry) Remove(ownerId int64, connector iface.IConnector) {
+	//check
+	if ownerId <= 0 || connector == nil {
+		return
+	}
+
+	//remove with locker
+	f.Lock()
+	defer f.Unlock()
+	connectors, ok := f.ownerMap[ownerId]
+	if !ok {
+		return
+	}
+	delete(connectors, connector)
+	if len(connectors) <= 0 {
+		delete(f.ownerMap, ownerId)
+	}
+}
+
+//get all connectors of owner
+func (f *OwnerRegistry) Get(ownerId int64) []iface.IConnector {
+	f.RLock()
+	defer f.RUnlock()
+	connectors, ok := f.ownerMap[ownerId]
+	if !ok {
+		return nil
+	}
+	result := make([]iface.IConnector, 0, len(connectors))
+	for k := range connectors {
+		result = append(result, k)
+	}
+	return result
+}
+
+//get total online owners
+func (f *OwnerRegistry) GetTotal() int {
+	f.RLock()
+	defer f.RUnlock()
+	return len(f.ownerMap)
+}
@@ -0,0 +1,328 @@
+package face
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * reliable delivery face
+ * - server wide increasing seq, seeded by start time to keep increasing after restart
+ * - messages retained per owner
+ * - retained until client ack, expired or dropped by size
+ * - retransmit un-acked messages when owner connect bound
+ */
+
+//retained message
+type reliableMsg struct {
+	seq        int64
+	data       []byte //encoded envelope
+	expireTime time.Time
+}
+
+//outbox of one owner
+type reliableOutbox struct {
+	msgs       []*reliableMsg //sorted by seq
+	connectors map[iface.IConnector]bool
+}
+
+//face info
+type Reliable struct {
+	seq         int64 //last message seq
+	maxMessages int
+	retainTime  time.Duration
+	ownerMap    map[int64]*reliableOutbox
+	wheel       *TimingWheel //retained message expire
+	sync.RWMutex
+}
+
+//construct
+func NewReliable() *Reliable {
+	this := &Reliable{
+		seq:         time.Now().UnixMilli() * 1000, //keep seq safe for js number
+		maxMessages: define.DefaultReliableMessages,
+		retainTime:  define.DefaultReliableRetainSeconds * time.Second,
+		ownerMap:    map[int64]*reliableOutbox{},
+		wheel:       NewTimingWheel(define.IdleWheelTick, define.IdleWheelSlots),
+	}
+	return this
+}
+
+//quit
+func (f *Reliable) Quit() {
+	f.wheel.Quit()
+	f.Lock()
+	defer f.Unlock()
+	f.ownerMap = map[int64]*reliableOutbox{}
+}
+
+//set conf, zero value kept default
+func (f *Reliable) SetConf(conf *gvar.ReliableConf) {
+	if conf == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	if conf.MaxMessages > 0 {
+		f.maxMessages = conf.MaxMessages
+	}
+	if conf.RetainTime > 0 {
+		f.retainTime = conf.RetainTime
+	}
+}
+
+//bind connector to owner and retransmit un-acked messages
+func (f *Reliable) Bind(oldOwnerId, ownerId int64, connector iface.IConnector) {
+	//check
+	if ownerId <= 0 || connector == nil {
+		return
+	}
+
+	//bind and collect un-acked messages with locker
+	f.Lock()
+	if oldOwnerId > 0 && oldOwnerId != ownerId {
+		f.unbind(oldOwnerId, connector)
+	}
+	outbox := f.getOutbox(ownerId)
+	outbox.connectors[connector] = true
+	f.prune(outbox)
+	pending := make([][]byte, 0, len(outbox.msgs))
+	for _, v := range outbox.msgs {
+		pending = append(pending, v.data)
+	}
+	f.Unlock()
+
+	//retransmit in order
+	for _, data := range pending {
+		if err := connector.Write(json.RawMessage(data), gvar.MessageTypeOfJson); err != nil {
+			break
+		}
+	}
+}
+
+//unbind closed connector
+func (f *Reliable) Unbind(connector iface.IConnector) {
+	if connector == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.unbind(connector.GetOwnerId(), connector)
+}
+
+//send data to owner with seq, retained until acked
+//data will be json encoded, return seq of message
+func (f *Reliable) Send(ownerId int64, data interface{}) (int64, error) {
+	//check
+	if ownerId <= 0 || data == nil {
+		return 0, errors.New("invalid parameter")
+	}
+	byteData, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+
+	//gen and retain message with locker
+	f.Lock()
+	outbox := f.getOutbox(ownerId)
+	f.seq++
+	msg := &reliableMsg{
+		seq:        f.seq,
+		expireTime: time.Now().Add(f.retainTime),
+	}
+	msg.data, err = json.Marshal(&gvar.ReliableMsg{
+		Rel:  define.ReliableOfMsg,
+		Seq:  msg.seq,
+		Data: byteData,
+	})
+	if err != nil {
+		f.Unlock()
+		return 0, err
+	}
+	if len(outbox.msgs) <= 0 {
+		f.wheel.Add(ownerId, f.retainTime, func() {
+			f.expire(ownerId)
+		})
+	}
+	outbox.msgs = append(outbox.msgs, msg)
+	if len(outbox.msgs) > f.maxMessages {
+		outbox.msgs = outbox.msgs[len(outbox.msgs)-f.maxMessages:]
+	}
+	connectors := make([]iface.IConnector, 0, len(outbox.connectors))
+	for k := range outbox.connectors {
+		connectors = append(connectors, k)
+	}
+	f.Unlock()
+
+	//write to online connectors
+	for _, v := range connectors {
+		v.Write(json.RawMessage(msg.data), gvar.MessageTypeOfJson)
+	}
+	return msg.seq, nil
+}
+
+//ack message of owner
+func (f *Reliable) Ack(ownerId, seq int64) {
+	f.Lock()
+	defer f.Unlock()
+	outbox, ok := f.ownerMap[ownerId]
+	if !ok {
+		return
+	}
+	for idx, v := range outbox.msgs {
+		if v.seq == seq {
+			outbox.msgs = append(outbox.msgs[:idx], outbox.msgs[idx+1:]...)
+			break
+		}
+		if v.seq > seq {
+			break
+		}
+	}
+	if len(outbox.msgs) <= 0 {
+		f.wheel.Remove(ownerId)
+	}
+	f.checkRemove(ownerId, outbox)
+}
+
+//get total un-acked messages of owner
+func (f *Reliable) GetPending(ownerId int64) int {
+	f.RLock()
+	defer f.RUnlock()
+	outbox, ok := f.ownerMap[ownerId]
+	if !ok {
+		return 0
+	}
+	return len(outbox.msgs)
+}
+
+//remove expired messages of owner and wait next expire
+func (f *Reliable) expire(ownerId int64) {
+	f.Lock()
+	defer f.Unlock()
+	outbox, ok := f.ownerMap[ownerId]
+	if !ok {
+		return
+	}
+	f.prune(outbox)
+	if len(outbox.msgs) > 0 {
+		f.wheel.Add(ownerId, time.Until(outbox.msgs[0].expireTime), func() {
+			f.expire(ownerId)
+		})
+	}
+	f.checkRemove(ownerId, outbox)
+}
+
+//remove expired messages without locker
+func (f *Reliable) prune(outbox *reliableOutbox) {
+	now := time.Now()
+	idx := 0
+	for idx < len(outbox.msgs) && !outbox.msgs[idx].expireTime.After(now) {
+		idx++
+	}
+	if idx > 0 {
+		outbox.msgs = outbox.msgs[idx:]
+	}
+}
+
+//unbind connector without locker
+func (f *Reliable) unbind(ownerId int64, connector iface.IConnector) {
+	outbox, ok := f.ownerMap[ownerId]
+	if !ok {
+		return
+	}
+	delete(outbox.connectors, connector)
+	f.checkRemove(ownerId, outbox)
+}
+
+//get or init outbox of owner without locker
+func (f *Reliable) getOutbox(ownerId int64) *reliableOutbox {
+	outbox, ok := f.ownerMap[ownerId]
+	if !ok {
+		outbox = &reliableOutbox{
+			msgs:       []*reliableMsg{},
+			connectors: map[iface.IConnector]bool{},
+		}
+		f.ownerMap[ownerId] = outbox
+	}
+	return outbox
+}
+
+//remove idle outbox without locker
+func (f *Reliable) checkRemove(ownerId int64, outbox *reliableOutbox) {
+	if len(outbox.msgs) <= 0 && len(outbox.connectors) <= 0 {
+		delete(f.ownerMap, ownerId)
+	}
+}
+
+//received seq window of client side, used for dedup
+type ReliableWindow struct {
+	seqMap  map[int64]bool
+	seqList []int64
+	sync.Mutex
+}
+
+//construct
+func NewReliableWindow() *ReliableWindow {
+	this := &ReliableWindow{
+		seqMap:  map[int64]bool{},
+		seqList: []int64{},
+	}
+	return this
+}
+
+//check and mark received seq
+//return true if seq received before
+func (f *ReliableWindow) Received(seq int64) bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.seqMap[seq] {
+		return true
+	}
+	f.seqMap[seq] = true
+	f.seqList = append(f.seqList, seq)
+	if len(f.seqList) > define.ReliableWindowSize {
+		delete(f.seqMap, f.seqList[0])
+		f.seqList = f.seqList[1:]
+	}
+	return false
+}
+
+//parse reliable message
+//return nil if not reliable message
+func ParseReliableMsg(data interface{}) *gvar.ReliableMsg {
+	var (
+		byteData []byte
+	)
+	switch v := data.(type) {
+	case []byte:
+		byteData = v
+	case string:
+		byteData = []byte(v)
+	case map[string]interface{}:
+		if _, ok := v["rel"]; !ok {
+			return nil
+		}
+		byteData, _ = json.Marshal(v)
+	default:
+		return nil
+	}
+	if len(byteData) <= 0 || byteData[0] != '{' {
+		return nil
+	}
+	msg := &gvar.ReliableMsg{}
+	if err := json.Unmarshal(byteData, msg); err != nil {
+		return nil
+	}
+	if msg.Rel != define.ReliableOfMsg && msg.Rel != define.ReliableOfAck {
+		return nil
+	}
+	return msg
+}
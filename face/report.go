@@ -0,0 +1,152 @@
+package face
+
+import (
+	"errors"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * delivery report face
+ * - record write result of one cast
+ * - merge reports of multi buckets
+ */
+
+//face info
+type deliveryRecorder struct {
+	report      *gvar.DeliveryReport
+	foundConns  map[int64]bool
+	foundOwners map[int64]bool
+}
+
+//construct
+func newDeliveryRecorder() *deliveryRecorder {
+	this := &deliveryRecorder{
+		report:      &gvar.DeliveryReport{},
+		foundConns:  map[int64]bool{},
+		foundOwners: map[int64]bool{},
+	}
+	return this
+}
+
+//record write result of one connect
+func (f *deliveryRecorder) record(connId int64, err error) {
+	f.report.Targets++
+	if err == nil {
+		f.report.Delivered++
+		return
+	}
+	f.report.Failed++
+	if f.report.Errors == nil {
+		f.report.Errors = map[int64]error{}
+	}
+	f.report.Errors[connId] = err
+}
+
+//mark assigned connect found
+func (f *deliveryRecorder) foundConn(connId int64) {
+	f.foundConns[connId] = true
+}
+
+//mark assigned owner found
+
+func (f *deliveryRecorder) foundOwner(ownerId int64) {
+	f.foundOwners[ownerId] = true
+}
+
+//finish and send report if need
+func (f *deliveryRecorder) finish(data *gvar.MsgData) {
+	if data.ReportChan == nil {
+		return
+	}
+	for _, connId := range data.ConnIds {
+		if connId > 0 && !f.foundConns[connId] {
+			f.report.MissingConnIds = append(f.report.MissingConnIds, connId)
+		}
+	}
+	for _, ownerId := range data.OwnerIds {
+		if ownerId > 0 && !f.foundOwners[ownerId] {
+			f.report.MissingOwnerIds = append(f.report.MissingOwnerIds, ownerId)
+		}
+	}
+	sendDeliveryReport(data.ReportChan, f.report)
+}
+
+//send report without block
+func sendDeliveryReport(reportChan chan *gvar.DeliveryReport, report *gvar.DeliveryReport) {
+	select {
+	case reportChan <- report:
+	default:
+	}
+}
+
+//merge reports of multi buckets
+//assigned id is missing only when missing in all reports
+func mergeDeliveryReports(msg *gvar.MsgData, reports []*gvar.DeliveryReport) *gvar.DeliveryReport {
+	result := &gvar.DeliveryReport{}
+	if len(reports) <= 0 {
+		//no bucket reached
+		result.MissingConnIds = append(result.MissingConnIds, msg.ConnIds...)
+		result.MissingOwnerIds = append(result.MissingOwnerIds, msg.OwnerIds...)
+		return result
+	}
+	missConns := map[int64]int{}
+	missOwners := map[int64]int{}
+	for _, report := range reports {
+		result.Targets += report.Targets
+		result.Delivered += report.Delivered
+		result.Failed += report.Failed
+		for connId, err := range report.Errors {
+			if result.Errors == nil {
+				result.Errors = map[int64]error{}
+			}
+			result.Errors[connId] = err
+		}
+		for _, connId := range report.MissingConnIds {
+			missConns[connId]++
+		}
+		for _, ownerId := range report.MissingOwnerIds {
+			missOwners[ownerId]++
+		}
+	}
+	for connId, times := range missConns {
+		if times >= len(reports) {
+			result.MissingConnIds = append(result.MissingConnIds, connId)
+		}
+	}
+	for ownerId, times := range missOwners {
+		if times >= len(reports) {
+			result.MissingOwnerIds = append(result.MissingOwnerIds, ownerId)
+		}
+	}
+	return result
+}
+
+//cast and wait delivery report
+func castWithReport(cast func(msg *gvar.MsgData) error, msg *gvar.MsgData) (*gvar.DeliveryReport, error) {
+	//check
+	if msg == nil {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//cast with report chan
+	reportMsg := *msg
+	reportMsg.ReportChan = make(chan *gvar.DeliveryReport, 1)
+	if err := cast(&reportMsg); err != nil {
+		return nil, err
+	}
+
+	//wait report
+	timer := time.NewTimer(define.DeliveryReportSeconds * time.Second)
+	defer timer.Stop()
+	select {
+	case report := <-reportMsg.ReportChan:
+		return report, nil
+	case <-timer.C:
+		return nil, errors.New("wait delivery report timeout")
+	}
+}
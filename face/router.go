@@ -3,8 +3,10 @@ package face
 import (
 	"errors"
 	"log"
+	"net/http"
 	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/andyzhou/websocket/define"
 	"github.com/andyzhou/websocket/gvar"
@@ -26,16 +28,18 @@ type Router struct {
 	connId      int64                  //inter atomic conn id counter
 	buckets     int                    //total buckets of config
 	bucketMap   map[int]iface.IBucket  //bucket map container
+	shared      *Shared                //shared components of buckets
+	admission   *Admission             //connect admission
 	Util
 }
 
 //construct
-func NewRouter(cfg *gvar.RouterConf) *Router {
+func NewRouter(cfg *gvar.RouterConf, registry *OwnerRegistry, pubSub *PubSub, reliable *Reliable) *Router {
 	this := &Router{
 		cfg: cfg,
 		bucketMap: map[int]iface.IBucket{},
 	}
-	this.interInit()
+	this.interInit(registry, pubSub, reliable)
 	return this
 }
 
@@ -47,6 +51,7 @@ func (f *Router) Quit()  {
 		f.bucketMap[k] = nil
 	}
 	f.bucketMap = nil
+	f.shared.Quit()
 }
 
 //get router config
@@ -109,9 +114,42 @@ func (f *Router) SetOwner(connId, ownerId int64, bucketIdxes ...int) error {
 		return errors.New("can't get bucket by id")
 	}
 
-	//set connector owner
-	err = targetBucket.SetOwner(connId, ownerId)
-	return err
+	//get connector
+	connector, err := targetBucket.GetConn(connId)
+	if err != nil {
+		return err
+	}
+	bucket, ok := targetBucket.(*Bucket)
+	if !ok {
+		return errors.New("invalid bucket")
+	}
+
+	//check owner policy with connects of all buckets,
+	//and set owner with owner locker
+	unlock := f.shared.owners.lock(ownerId)
+	kicks, err := checkOwnerPolicy(f.cfg.OwnerPolicy, connId, f.GetConnectorsByOwner(ownerId))
+	if err != nil {
+		unlock()
+		connector.CloseWithCode(define.CloseCodePolicyViolation, err.Error())
+		return err
+	}
+	oldOwnerId := bucket.setOwnerIndex(connector, ownerId)
+	unlock()
+
+	//kick and bind owner without locker, it may write to connector
+	kickOwnerConns(kicks)
+	f.shared.BindOwner(oldOwnerId, ownerId, connector)
+	return nil
+}
+
+//get all connectors of owner from all buckets
+func (f *Router) GetConnectorsByOwner(ownerId int64) []iface.IConnector {
+	result := make([]iface.IConnector, 0)
+	for _, v := range f.bucketMap {
+		connectors, _ := v.GetConnsByOwnerId(ownerId)
+		result = append(result, connectors...)
+	}
+	return result
 }
 
 //broad cast data
@@ -121,24 +159,63 @@ func (f *Router) Cast(msg *gvar.MsgData) error {
 		return errors.New("invalid parameter")
 	}
 
-	//cast to assigned buckets
+	//get target buckets
+	buckets := make([]iface.IBucket, 0)
 	if len(msg.BucketIds) > 0 {
+		//cast to assigned buckets
 		for _, idx := range msg.BucketIds {
 			v, ok := f.bucketMap[idx]
 			if ok && v != nil {
-				v.Broadcast(msg)
+				buckets = append(buckets, v)
 			}
 		}
-		return nil
+	}else{
+		//cast to all buckets
+		for _, v := range f.bucketMap {
+			buckets = append(buckets, v)
+		}
 	}
 
-	//cast to all buckets
-	for _, v := range f.bucketMap {
+	//keep message for offline owners
+	f.shared.KeepOffline(msg, f.cfg.MessageType, func(ownerId int64) bool {
+		return len(f.GetConnectorsByOwner(ownerId)) > 0
+	})
+
+	//cast with merged delivery report
+	if msg.ReportChan != nil {
+		return f.castWithMergedReport(msg, buckets)
+	}
+	for _, v := range buckets {
 		v.Broadcast(msg)
 	}
 	return nil
 }
 
+//cast message at assigned time, return id as cancel handle
+func (f *Router) CastAt(at time.Time, msg *gvar.MsgData) (int64, error) {
+	return f.shared.Scheduler.Add(at, "", msg, f.Cast)
+}
+
+//cast message after delay, return id as cancel handle
+func (f *Router) CastAfter(delay time.Duration, msg *gvar.MsgData) (int64, error) {
+	return f.CastAt(time.Now().Add(delay), msg)
+}
+
+//cancel scheduled cast
+func (f *Router) CancelCast(id int64) error {
+	return f.shared.Scheduler.Cancel(id, "")
+}
+
+//get scheduled casts, ordered by send time
+func (f *Router) GetScheduledCasts() []*gvar.ScheduledMsg {
+	return f.shared.Scheduler.GetScheduled("")
+}
+
+//cast message and wait delivery report
+func (f *Router) CastWithReport(msg *gvar.MsgData) (*gvar.DeliveryReport, error) {
+	return castWithReport(f.Cast, msg)
+}
+
 //switch target bucket
 func (f *Router) SwitchBucket(connectId int64, from, to int) error {
 	//check
@@ -165,6 +242,76 @@ func (f *Router) SwitchBucket(connectId int64, from, to int) error {
 	return err
 }
 
+//register rpc method handler for all buckets
+func (f *Router) RegisterRpc(method string, handler gvar.RpcHandler) error {
+	return f.shared.RegisterRpc(method, handler)
+}
+
+//register json rpc 2.0 method handler for all buckets
+func (f *Router) RegisterJsonRpc(method string, handler interface{}) error {
+	return f.shared.RegisterJsonRpc(method, handler)
+}
+
+//send json rpc 2.0 notification
+//target used for bucket, owner, connect and filter, nil means all connects
+func (f *Router) Notify(method string, params interface{}, target *gvar.MsgData) error {
+	data, err := GenJsonRpcNotify(f.cfg.MessageType, method, params)
+	if err != nil {
+		return err
+	}
+	msg := &gvar.MsgData{}
+	if target != nil {
+		*msg = *target
+	}
+	msg.Data = data
+	msg.WriteInQueue = false
+	return f.Cast(msg)
+}
+
+//register named event handler for all buckets
+func (f *Router) On(event string, handler gvar.EventHandler) error {
+	return f.shared.On(event, handler)
+}
+
+//set owner offline message store, like file backed wal
+//should be called before any cast, old store will quit
+func (f *Router) SetOfflineStore(store iface.IOfflineStore) {
+	old := f.shared.Offline
+	f.shared.Offline = store
+	if old != nil && old != store {
+		old.Quit()
+	}
+}
+
+//emit named event to connects
+//target used for bucket, owner, connect and filter, nil means all connects
+func (f *Router) Emit(event string, data interface{}, target *gvar.MsgData) error {
+	msg, err := GenEventMsg(event, data, nil)
+	if err != nil {
+		return err
+	}
+	castData, err := genJsonCastData(f.cfg.MessageType, msg)
+	if err != nil {
+		return err
+	}
+	castMsg := &gvar.MsgData{}
+	if target != nil {
+		*castMsg = *target
+	}
+	castMsg.Data = castData
+	castMsg.WriteInQueue = false
+	return f.Cast(castMsg)
+}
+
+//check connect admission before handshake
+//return release func if admitted
+func (f *Router) Admit(req *http.Request) (func(), error) {
+	if f.admission == nil {
+		return func() {}, nil
+	}
+	return f.admission.Acquire(req)
+}
+
 //websocket request entry
 //ws conn init first time
 func (f *Router) Entry(conn *websocket.Conn) {
@@ -179,6 +326,12 @@ func (f *Router) Entry(conn *websocket.Conn) {
 		return
 	}
 
+	//resume parked session by token
+	if connector := f.resumeSession(conn); connector != nil {
+		<-connector.Done()
+		return
+	}
+
 	//gen new connect id
 	if f.cfg.CBForGenConnId != nil {
 		newConnId = f.cfg.CBForGenConnId()
@@ -209,17 +362,65 @@ func (f *Router) Entry(conn *websocket.Conn) {
 		return
 	}
 
-	//add new connect into target bucket
-	targetBucket.AddConn(newConnId, conn)
+	//add new connect into target bucket, checked by connects limit
+	err = targetBucket.AddConn(newConnId, conn)
+	if err != nil {
+		log.Printf("router %v, add connect failed, err:%v\n", f.cfg.Uri, err)
+		return
+	}
+	connector, _ := targetBucket.GetConn(newConnId)
+	if connector == nil {
+		return
+	}
 
-	//keep the new connect active
-	select {}
+	//keep the new connect active until closed
+	<-connector.Done()
 }
 
 ////////////////
 //private func
 ////////////////
 
+//cast to buckets, merge reports of all buckets and send once
+func (f *Router) castWithMergedReport(msg *gvar.MsgData, buckets []iface.IBucket) error {
+	//cast with inter report chan
+	reportChan := msg.ReportChan
+	bucketMsg := *msg
+	bucketMsg.ReportChan = make(chan *gvar.DeliveryReport, len(buckets))
+	casts := 0
+	for _, v := range buckets {
+		if err := v.Broadcast(&bucketMsg); err == nil {
+			casts++
+		}
+	}
+
+	//collect and merge reports
+	go func() {
+		reports := make([]*gvar.DeliveryReport, 0, casts)
+		timer := time.NewTimer(define.DeliveryReportSeconds * time.Second)
+		defer timer.Stop()
+		for len(reports) < casts {
+			select {
+			case report := <-bucketMsg.ReportChan:
+				reports = append(reports, report)
+			case <-timer.C:
+				casts = len(reports)
+			}
+		}
+		sendDeliveryReport(reportChan, mergeDeliveryReports(msg, reports))
+	}()
+	return nil
+}
+
+//resume parked session by token query para
+func (f *Router) resumeSession(conn *websocket.Conn) iface.IConnector {
+	if f.shared.Sessions == nil {
+		return nil
+	}
+	queryParas, _ := f.GetQueryParas(conn)
+	return f.shared.ResumeSession(queryParas.Get(define.QueryParaOfSession), conn)
+}
+
 //get bucket by idx
 func (f *Router) getBucket(idx int) (iface.IBucket, error) {
 	//check
@@ -251,7 +452,7 @@ func (f *Router) getBucketByConnId(connId int64) (iface.IBucket, error) {
 }
 
 //inter init
-func (f *Router) interInit() {
+func (f *Router) interInit(registry *OwnerRegistry, pubSub *PubSub, reliable *Reliable) {
 	//init inter counter
 	atomic.StoreInt64(&f.connId, 0)
 
@@ -261,9 +462,34 @@ func (f *Router) interInit() {
 		f.buckets = define.DefaultBuckets
 	}
 
+	//init connect admission
+	f.admission = NewAdmission(f.cfg.MaxConns, f.cfg.MaxConnsPerIp)
+
+	//init shared components
+	limiter := NewRateLimiter(f.cfg.ConnRateLimit, f.cfg.OwnerRateLimit, f.cfg.IpRateLimit)
+	f.shared = NewShared(limiter, f.cfg.IdleTimeout, registry, pubSub)
+	if f.cfg.EnableRpc {
+		f.shared.Rpc = NewRpcRouter()
+	}
+	if f.cfg.JsonRpc {
+		f.shared.JsonRpc = NewJsonRpcRouter()
+	}
+	if f.cfg.EnableEvent {
+		f.shared.Event = NewEventRouter()
+	}
+	if f.cfg.EnableReliable {
+		f.shared.Reliable = reliable
+	}
+	if f.cfg.ResumeGrace > 0 {
+		f.shared.Sessions = NewSessionStore(f.cfg.ResumeGrace)
+	}
+	if f.cfg.OfflineSize > 0 {
+		f.shared.Offline = NewMemOffline(f.cfg.OfflineSize, f.cfg.OfflineTTL)
+	}
+
 	//init inter buckets container
 	for i := 0; i < f.buckets; i++ {
-		bucket := NewBucket(f, i, f.cfg)
+		bucket := NewBucket(f, i, f.cfg, f.shared)
 		f.bucketMap[i] = bucket
 	}
 }
\ No newline at end of file
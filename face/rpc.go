@@ -0,0 +1,241 @@
+package face
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * request and response rpc face
+ * - method handlers shared by router or dynamic
+ * - pending calls of one connect, matched by correlation id
+ */
+
+//errors of rpc
+var (
+	ErrRpcNotEnabled = errors.New("rpc not enabled")
+	ErrRpcClosed     = &gvar.RpcError{Code: gvar.RpcCodeOfClosed, Message: "connect closed"}
+)
+
+//face info
+type RpcRouter struct {
+	handlers map[string]gvar.RpcHandler //method -> handler
+	sync.RWMutex
+}
+
+//construct
+func NewRpcRouter() *RpcRouter {
+	this := &RpcRouter{
+		handlers: map[string]gvar.RpcHandler{},
+	}
+	return this
+}
+
+//register method handler
+func (f *RpcRouter) Register(method string, handler gvar.RpcHandler) error {
+	//check
+	if method == "" || handler == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//register with locker
+	f.Lock()
+	defer f.Unlock()
+	f.handlers[method] = handler
+	return nil
+}
+
+//serve one request, return response
+func (f *RpcRouter) Serve(connector interface{}, req *gvar.RpcMsg) *gvar.RpcMsg {
+	var (
+		m any = nil
+	)
+	resp := &gvar.RpcMsg{
+		Rpc: define.RpcOfResponse,
+		Id:  req.Id,
+	}
+
+	//get handler
+	f.RLock()
+	handler, ok := f.handlers[req.Method]
+	f.RUnlock()
+	if !ok || handler == nil {
+		resp.Error = &gvar.RpcError{
+			Code:    gvar.RpcCodeOfUnknownMethod,
+			Message: fmt.Sprintf("unknown method %v", req.Method),
+		}
+		return resp
+	}
+
+	//run handler
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if pErr := recover(); pErr != m {
+				log.Printf("rpc method %v panic, err:%v\n", req.Method, pErr)
+				err = fmt.Errorf("method panic: %v", pErr)
+			}
+		}()
+		return handler(connector, req.Data)
+	}()
+	if err == nil {
+		resp.Data, err = json.Marshal(result)
+	}
+	if err != nil {
+		rpcErr, ok := err.(*gvar.RpcError)
+		if !ok {
+			rpcErr = &gvar.RpcError{
+				Code:    gvar.RpcCodeOfInternal,
+				Message: err.Error(),
+			}
+		}
+		resp.Data = nil
+		resp.Error = rpcErr
+	}
+	return resp
+}
+
+//face info
+type RpcPending struct {
+	seq     int64
+	callMap map[int64]chan *gvar.RpcMsg //id -> response chan
+	closed  bool
+	sync.Mutex
+}
+
+//construct
+func NewRpcPending() *RpcPending {
+	this := &RpcPending{
+		callMap: map[int64]chan *gvar.RpcMsg{},
+	}
+	return this
+}
+
+//quit, fail all pending calls
+func (f *RpcPending) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	f.closed = true
+	for id, respChan := range f.callMap {
+		respChan <- &gvar.RpcMsg{
+			Rpc:   define.RpcOfResponse,
+			Id:    id,
+			Error: ErrRpcClosed,
+		}
+		delete(f.callMap, id)
+	}
+}
+
+//call remote method and wait response
+//payload will be json encoded, use json.RawMessage for encoded data
+func (f *RpcPending) Call(
+	ctx context.Context,
+	method string,
+	payload interface{},
+	write func(req *gvar.RpcMsg) error) (json.RawMessage, error) {
+	//check
+	if method == "" || write == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	//init request
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req := &gvar.RpcMsg{
+		Rpc:    define.RpcOfRequest,
+		Id:     atomic.AddInt64(&f.seq, 1),
+		Method: method,
+		Data:   data,
+	}
+
+	//add pending call with locker
+	respChan := make(chan *gvar.RpcMsg, 1)
+	f.Lock()
+	if f.closed {
+		f.Unlock()
+		return nil, ErrRpcClosed
+	}
+	f.callMap[req.Id] = respChan
+	f.Unlock()
+	defer f.remove(req.Id)
+
+	//send and wait response
+	if err = write(req); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-respChan:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//resolve pending call by response
+func (f *RpcPending) Resolve(resp *gvar.RpcMsg) bool {
+	f.Lock()
+	defer f.Unlock()
+	respChan, ok := f.callMap[resp.Id]
+	if !ok {
+		return false
+	}
+	delete(f.callMap, resp.Id)
+	respChan <- resp
+	return true
+}
+
+//remove pending call
+func (f *RpcPending) remove(id int64) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.callMap, id)
+}
+
+//parse rpc message
+//return nil if not rpc message
+func ParseRpcMsg(data interface{}) *gvar.RpcMsg {
+	var (
+		byteData []byte
+	)
+	switch v := data.(type) {
+	case []byte:
+		byteData = v
+	case string:
+		byteData = []byte(v)
+	case map[string]interface{}:
+		if _, ok := v["rpc"]; !ok {
+			return nil
+		}
+		byteData, _ = json.Marshal(v)
+	default:
+		return nil
+	}
+	if len(byteData) <= 0 || byteData[0] != '{' {
+		return nil
+	}
+	msg := &gvar.RpcMsg{}
+	if err := json.Unmarshal(byteData, msg); err != nil {
+		return nil
+	}
+	if msg.Rpc != define.RpcOfRequest && msg.Rpc != define.RpcOfResponse {
+		return nil
+	}
+	return msg
+}
@@ -0,0 +1,161 @@
+package face
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * delayed and scheduled cast face
+ * - all tasks run on one timing wheel, created on first task
+ * - task id is unique in process, used as cancel handle
+ */
+
+//server wide scheduled task id
+var scheduleId int64
+
+//scheduled task
+type scheduleTask struct {
+	msg  *gvar.ScheduledMsg
+	cast func(msg *gvar.MsgData) error
+}
+
+//face info
+type Scheduler struct {
+	wheel   *TimingWheel            //nil before first task
+	taskMap map[int64]*scheduleTask //id -> task
+	closed  bool
+	sync.Mutex
+}
+
+//construct
+func NewScheduler() *Scheduler {
+	this := &Scheduler{
+		taskMap: map[int64]*scheduleTask{},
+	}
+	return this
+}
+
+//quit, pending tasks dropped
+func (f *Scheduler) Quit() {
+	f.Lock()
+	defer f.Unlock()
+	f.closed = true
+	if f.wheel != nil {
+		f.wheel.Quit()
+		f.wheel = nil
+	}
+	f.taskMap = map[int64]*scheduleTask{}
+}
+
+//add task run cast at assigned time, passed time means run at next tick
+//return task id as cancel handle
+func (f *Scheduler) Add(
+	at time.Time,
+	target string,
+	msg *gvar.MsgData,
+	cast func(msg *gvar.MsgData) error) (int64, error) {
+	//check
+	if msg == nil || msg.Data == nil || cast == nil {
+		return 0, errors.New("invalid parameter")
+	}
+
+	//add with locker
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return 0, errors.New("scheduler had quit")
+	}
+	if f.wheel == nil {
+		f.wheel = NewTimingWheel(define.ScheduleWheelTick, define.ScheduleWheelSlots)
+	}
+	id := atomic.AddInt64(&scheduleId, 1)
+	f.taskMap[id] = &scheduleTask{
+		msg: &gvar.ScheduledMsg{
+			Id:     id,
+			Target: target,
+			SendAt: at,
+			Msg:    msg,
+		},
+		cast: cast,
+	}
+	f.wheel.Add(id, time.Until(at), func() {
+		f.run(id)
+	})
+	return id, nil
+}
+
+//cancel task of target
+func (f *Scheduler) Cancel(id int64, target string) error {
+	f.Lock()
+	defer f.Unlock()
+	task, ok := f.taskMap[id]
+	if !ok || task.msg.Target != target {
+		return errors.New("no such scheduled message")
+	}
+	delete(f.taskMap, id)
+	f.wheel.Remove(id)
+	return nil
+}
+
+//cancel all tasks of target
+func (f *Scheduler) CancelTarget(target string) int {
+	f.Lock()
+	defer f.Unlock()
+	total := 0
+	for id, task := range f.taskMap {
+		if task.msg.Target != target {
+			continue
+		}
+		delete(f.taskMap, id)
+		f.wheel.Remove(id)
+		total++
+	}
+	return total
+}
+
+//get pending tasks of target, ordered by send time
+func (f *Scheduler) GetScheduled(target string) []*gvar.ScheduledMsg {
+	f.Lock()
+	result := make([]*gvar.ScheduledMsg, 0)
+	for _, task := range f.taskMap {
+		if task.msg.Target != target {
+			continue
+		}
+		msg := *task.msg
+		result = append(result, &msg)
+	}
+	f.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SendAt.Equal(result[j].SendAt) {
+			return result[i].Id < result[j].Id
+		}
+		return result[i].SendAt.Before(result[j].SendAt)
+	})
+	return result
+}
+
+//run expired task, skip canceled one
+func (f *Scheduler) run(id int64) {
+	f.Lock()
+	task, ok := f.taskMap[id]
+	if ok {
+		delete(f.taskMap, id)
+	}
+	f.Unlock()
+	if !ok {
+		return
+	}
+	if err := task.cast(task.msg.Msg); err != nil {
+		log.Printf("scheduled message %v cast failed, err:%v\n", id, err)
+	}
+}
@@ -0,0 +1,231 @@
+package face
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+	"golang.org/x/net/websocket"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * session resumption face
+ * - issue resume token when connected
+ * - park lost connector for grace window, keep written messages
+ * - resume with token, new connector take over conn id, owner, props and groups
+ */
+
+//parked session
+type parkedSession struct {
+	connector iface.IConnector
+	resume    func(old iface.IConnector, conn *websocket.Conn) (iface.IConnector, error)
+}
+
+//face info
+type SessionStore struct {
+	grace     time.Duration
+	tokenMap  map[int64]string          //connId -> token
+	parkedMap map[string]*parkedSession //token -> parked session
+	wheel     *TimingWheel              //parked session expire
+	sync.Mutex
+}
+
+//construct
+func NewSessionStore(grace time.Duration) *SessionStore {
+	this := &SessionStore{
+		grace:     grace,
+		tokenMap:  map[int64]string{},
+		parkedMap: map[string]*parkedSession{},
+		wheel:     NewTimingWheel(define.IdleWheelTick, define.IdleWheelSlots),
+	}
+	return this
+}
+
+//quit
+func (f *SessionStore) Quit() {
+	f.wheel.Quit()
+}
+
+//issue token of connector and send it by control message
+//token kept while session alive, so resumed connector got the same one
+func (f *SessionStore) Issue(connector iface.IConnector, ctrl string) error {
+	//check
+	if connector == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//get or gen token with locker
+	f.Lock()
+	token, ok := f.tokenMap[connector.GetConnId()]
+	if !ok {
+		token = genSessionToken()
+		f.tokenMap[connector.GetConnId()] = token
+	}
+	f.Unlock()
+
+	//send token to client
+	msg := &gvar.ControlMsg{
+		Ctrl:  ctrl,
+		Token: token,
+	}
+	return connector.Write(msg, gvar.MessageTypeOfJson)
+}
+
+//park connector lost by network without close frame
+//return false if not parked, closed by server or client, or parked before
+func (f *SessionStore) Park(
+	connector iface.IConnector,
+	resume func(old iface.IConnector, conn *websocket.Conn) (iface.IConnector, error),
+	expire func()) bool {
+	//check
+	if connector == nil || resume == nil || expire == nil {
+		return false
+	}
+	reason := connector.GetCloseReason()
+	if reason == nil || reason.Initiator != gvar.CloseByNetwork {
+		return false
+	}
+	connId := connector.GetConnId()
+
+	//add into parked map with locker
+	f.Lock()
+	token, ok := f.tokenMap[connId]
+	if !ok {
+		f.Unlock()
+		return false
+	}
+	if _, ok = f.parkedMap[token]; ok {
+		f.Unlock()
+		return false
+	}
+	f.parkedMap[token] = &parkedSession{
+		connector: connector,
+		resume:    resume,
+	}
+	f.Unlock()
+
+	//keep written messages until resumed or expired
+	connector.Park(define.SessionMissedMessages)
+	f.wheel.Add(connId, f.grace, func() {
+		if f.take(token) != nil {
+			expire()
+		}
+	})
+	return true
+}
+
+//resume parked session with new connect
+func (f *SessionStore) Resume(token string, conn *websocket.Conn) (iface.IConnector, error) {
+	//check
+	if token == "" || conn == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	session := f.take(token)
+	if session == nil {
+		return nil, errors.New("session not found or expired")
+	}
+	f.wheel.Remove(session.connector.GetConnId())
+	return session.resume(session.connector, conn)
+}
+
+//remove token and parked session of closed connector
+func (f *SessionStore) Remove(connId int64) {
+	f.Lock()
+	token, ok := f.tokenMap[connId]
+	if ok {
+		delete(f.tokenMap, connId)
+		delete(f.parkedMap, token)
+	}
+	f.Unlock()
+	if ok {
+		f.wheel.Remove(connId)
+	}
+}
+
+//get total parked sessions
+func (f *SessionStore) GetParked() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.parkedMap)
+}
+
+//take parked session
+func (f *SessionStore) take(token string) *parkedSession {
+	f.Lock()
+	defer f.Unlock()
+	session, ok := f.parkedMap[token]
+	if !ok {
+		return nil
+	}
+	delete(f.parkedMap, token)
+	return session
+}
+
+//inherit owner and props of parked connector
+func inheritConn(old, connector iface.IConnector) {
+	connector.SetOwnerId(old.GetOwnerId())
+	for k, v := range old.GetProps() {
+		connector.SetProp(k, v)
+	}
+}
+
+//replay kept messages of parked connector
+func replayMissed(old, connector iface.IConnector) {
+	for _, v := range old.TakeMissed() {
+		if v.InQueue {
+			byteData, _ := v.Data.([]byte)
+			connector.QueueWrite(byteData, v.DirectWrite)
+		}else{
+			connector.Write(v.Data, v.MessageType)
+		}
+	}
+}
+
+//gen random resume token
+func genSessionToken() string {
+	buff := make([]byte, define.SessionTokenSize)
+	rand.Read(buff)
+	return hex.EncodeToString(buff)
+}
+
+//kept messages of parked connector
+type missedQueue struct {
+	maxMessages int
+	msgs        []*gvar.MissedMsg
+	sync.Mutex
+}
+
+//construct
+func newMissedQueue(maxMessages int) *missedQueue {
+	this := &missedQueue{
+		maxMessages: maxMessages,
+		msgs:        []*gvar.MissedMsg{},
+	}
+	return this
+}
+
+//add message, drop the oldest when full
+func (f *missedQueue) add(msg *gvar.MissedMsg) {
+	f.Lock()
+	defer f.Unlock()
+	f.msgs = append(f.msgs, msg)
+	if f.maxMessages > 0 && len(f.msgs) > f.maxMessages {
+		f.msgs = f.msgs[len(f.msgs)-f.maxMessages:]
+	}
+}
+
+//take all messages
+func (f *missedQueue) take() []*gvar.MissedMsg {
+	f.Lock()
+	defer f.Unlock()
+	msgs := f.msgs
+	f.msgs = []*gvar.MissedMsg{}
+	return msgs
+}
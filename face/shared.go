@@ -0,0 +1,249 @@
+package face
+
+import (
+	"log"
+	"time"
+
+	"github.com/andyzhou/websocket/define"
+	"github.com/andyzhou/websocket/gvar"
+	"github.com/andyzhou/websocket/iface"
+	"golang.org/x/net/websocket"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * shared components of router or dynamic
+ * - used by all buckets or groups
+ */
+
+//face info
+type Shared struct {
+	Limiter   *RateLimiter        //inbound rate limiter
+	Wheel     *TimingWheel        //idle check timing wheel
+	Registry  *OwnerRegistry      //server wide owner registry
+	Members   *Membership         //group membership of multi groups mode
+	Rpc       *RpcRouter          //rpc method handlers
+	JsonRpc   *JsonRpcRouter      //json rpc 2.0 method handlers
+	Event     *EventRouter        //named event handlers
+	PubSub    *PubSub             //server wide topic pub/sub
+	Reliable  *Reliable           //server wide reliable delivery, nil means disabled
+	Sessions  *SessionStore       //parked sessions for resume, nil means disabled
+	History   iface.IHistoryStore //group history store, nil means disabled
+	Offline   iface.IOfflineStore //owner offline message store, nil means disabled
+	Scheduler *Scheduler          //delayed and scheduled cast
+	owners    ownerLocker         //serialize owner policy check and bind
+}
+
+//construct
+func NewShared(limiter *RateLimiter, idleTimeout time.Duration, registry *OwnerRegistry, pubSub *PubSub) *Shared {
+	this := &Shared{
+		Limiter:   limiter,
+		Registry:  registry,
+		PubSub:    pubSub,
+		Scheduler: NewScheduler(),
+	}
+	if idleTimeout > 0 {
+		this.Wheel = NewTimingWheel(define.IdleWheelTick, define.IdleWheelSlots)
+	}
+	return this
+}
+
+//bind connector to owner in registry, reliable and offline store
+func (f *Shared) BindOwner(oldOwnerId, ownerId int64, connector iface.IConnector) {
+	if f.Registry != nil {
+		if oldOwnerId > 0 && oldOwnerId != ownerId {
+			f.Registry.Remove(oldOwnerId, connector)
+		}
+		f.Registry.Add(ownerId, connector)
+	}
+	if f.Reliable != nil {
+		f.Reliable.Bind(oldOwnerId, ownerId, connector)
+	}
+	f.deliverOffline(ownerId, connector)
+}
+
+//deliver kept offline messages of owner in order
+//undelivered messages pushed back before new kept messages
+func (f *Shared) deliverOffline(ownerId int64, connector iface.IConnector) {
+	//check
+	if f.Offline == nil || ownerId <= 0 || connector == nil {
+		return
+	}
+	msgs, err := f.Offline.Take(ownerId)
+	if err != nil {
+		log.Printf("take offline messages failed, err:%v\n", err)
+	}
+	for i, msg := range msgs {
+		if err = writeOfflineMsg(connector, msg); err != nil {
+			if err = f.Offline.PushFront(ownerId, msgs[i:]); err != nil {
+				log.Printf("push back offline messages failed, err:%v\n", err)
+			}
+			return
+		}
+	}
+}
+
+//check owner has connect in server wide registry
+//hasConn used if no registry
+func (f *Shared) hasOwnerConn(ownerId int64, hasConn func(ownerId int64) bool) bool {
+	if f.Registry == nil {
+		return hasConn(ownerId)
+	}
+	return len(f.Registry.Get(ownerId)) > 0
+}
+
+//keep owner targeted message for owners without connect in server
+//hasConn only used if no server wide registry
+func (f *Shared) KeepOffline(msg *gvar.MsgData, messageType int, hasConn func(ownerId int64) bool) {
+	//check
+	if f.Offline == nil || msg == nil || len(msg.OwnerIds) <= 0 {
+		return
+	}
+	var offlineMsg *gvar.OfflineMsg
+	for _, ownerId := range msg.OwnerIds {
+		if ownerId <= 0 || f.hasOwnerConn(ownerId, hasConn) {
+			continue
+		}
+		if offlineMsg == nil {
+			offlineMsg = genOfflineMsg(msg, messageType)
+			if offlineMsg == nil {
+				return
+			}
+		}
+		if err := f.Offline.Push(ownerId, offlineMsg); err != nil {
+			log.Printf("keep offline message failed, err:%v\n", err)
+		}
+	}
+}
+
+//unbind closed connector from registry
+func (f *Shared) UnbindOwner(connector iface.IConnector) {
+	if f.Registry == nil || connector == nil {
+		return
+	}
+	f.Registry.Remove(connector.GetOwnerId(), connector)
+}
+
+//release closed connector from registry and topic subscriptions
+func (f *Shared) ReleaseConn(connector iface.IConnector) {
+	if connector == nil {
+		return
+	}
+	f.UnbindOwner(connector)
+	if f.PubSub != nil {
+		f.PubSub.UnsubscribeAll(connector)
+	}
+	if f.Reliable != nil {
+		f.Reliable.Unbind(connector)
+	}
+	if f.Sessions != nil {
+		f.Sessions.Remove(connector.GetConnId())
+	}
+}
+
+//issue session token for new connector
+func (f *Shared) IssueSession(connector iface.IConnector) {
+	if f.Sessions == nil || connector == nil {
+		return
+	}
+	f.Sessions.Issue(connector, define.ControlOfSession)
+}
+
+//resume parked session with token
+//return nil if disabled or resume failed
+func (f *Shared) ResumeSession(token string, conn *websocket.Conn) iface.IConnector {
+	if f.Sessions == nil || token == "" {
+		return nil
+	}
+	connector, err := f.Sessions.Resume(token, conn)
+	if err != nil {
+		log.Printf("resume session failed, err:%v\n", err)
+		return nil
+	}
+	return connector
+}
+
+//move registry, subscriptions and reliable binding to resumed connector
+//then replay kept messages of parked connector
+func (f *Shared) ResumeConn(old, connector iface.IConnector) {
+	//check
+	if old == nil || connector == nil {
+		return
+	}
+
+	//rebind owner and subscriptions
+	ownerId := connector.GetOwnerId()
+	if f.Registry != nil && ownerId > 0 {
+		f.Registry.Remove(ownerId, old)
+		f.Registry.Add(ownerId, connector)
+	}
+	if f.PubSub != nil {
+		f.PubSub.Transfer(old, connector)
+	}
+
+	//notify client and replay kept messages
+	if f.Sessions != nil {
+		f.Sessions.Issue(connector, define.ControlOfResumed)
+	}
+	replayMissed(old, connector)
+
+	//retransmit un-acked reliable messages
+	if f.Reliable != nil {
+		f.Reliable.Unbind(old)
+		f.Reliable.Bind(0, ownerId, connector)
+	}
+}
+
+//get pub/sub for handling sub control message of connector
+//nil means control message disabled
+func (f *Shared) GetPubSub(enable bool) *PubSub {
+	if !enable {
+		return nil
+	}
+	return f.PubSub
+}
+
+//register rpc method handler
+func (f *Shared) RegisterRpc(method string, handler gvar.RpcHandler) error {
+	if f.Rpc == nil {
+		return ErrRpcNotEnabled
+	}
+	return f.Rpc.Register(method, handler)
+}
+
+//register json rpc 2.0 method handler
+func (f *Shared) RegisterJsonRpc(method string, handler interface{}) error {
+	if f.JsonRpc == nil {
+		return ErrRpcNotEnabled
+	}
+	return f.JsonRpc.Register(method, handler)
+}
+
+//register named event handler
+func (f *Shared) On(event string, handler gvar.EventHandler) error {
+	if f.Event == nil {
+		return ErrEventNotEnabled
+	}
+	return f.Event.On(event, handler)
+}
+
+//quit
+func (f *Shared) Quit() {
+	if f.Limiter != nil {
+		f.Limiter.Quit()
+	}
+	if f.Wheel != nil {
+		f.Wheel.Quit()
+	}
+	if f.Sessions != nil {
+		f.Sessions.Quit()
+	}
+	if f.History != nil {
+		f.History.Quit()
+	}
+	if f.Offline != nil {
+		f.Offline.Quit()
+	}
+	f.Scheduler.Quit()
+}
@@ -2,9 +2,12 @@ package face
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
 	"errors"
 	"math/rand"
+	"net"
+	"net/http"
 	"net/url"
 	"reflect"
 	"time"
@@ -54,6 +57,53 @@ func (f *Util) GetPathPara(conn *websocket.Conn, paraName string) (string, error
 	return paraVal, nil
 }
 
+//send close frame with status code and reason, then close connect
+func (f *Util) CloseConnWithCode(conn *websocket.Conn, code int, reason string) error {
+	//check
+	if conn == nil || !IsSendableCloseCode(code) {
+		return errors.New("invalid parameter")
+	}
+	reason = TruncateCloseReason(reason)
+
+	//send close frame, then close transport only
+	err := f.SendCloseFrame(conn, code, reason)
+	CloseTransport(conn)
+	return err
+}
+
+//send close frame with status code and reason
+func (f *Util) SendCloseFrame(conn *websocket.Conn, code int, reason string) error {
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	return closeFrameCodec.Send(conn, payload)
+}
+
+//get remote ip of request
+func (f *Util) GetRemoteIp(conn *websocket.Conn) string {
+	//check
+	if conn == nil {
+		return ""
+	}
+	return f.GetRequestIp(conn.Request())
+}
+
+//get remote ip of http request
+func (f *Util) GetRequestIp(req *http.Request) string {
+	//check
+	if req == nil {
+		return ""
+	}
+
+	//split host and port
+	remoteAddr := req.RemoteAddr
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
 //check chan is closed or not
 //true:closed, false:opening
 func (f *Util) IsChanClosed(ch interface{}) (bool, error) {
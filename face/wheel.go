@@ -0,0 +1,172 @@
+package face
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * hashed timing wheel face
+ * - one ticker for all tasks
+ * - add or remove task with O(1)
+ */
+
+//wheel task
+type wheelTask struct {
+	key    int64
+	rounds int
+	slot   int
+	cb     func()
+}
+
+//face info
+type TimingWheel struct {
+	tick      time.Duration
+	slots     []map[int64]*wheelTask //slot -> key -> task
+	taskMap   map[int64]*wheelTask   //key -> task
+	pos       int
+	closeChan chan bool
+	closeOnce sync.Once
+	locker    sync.Mutex
+}
+
+//construct
+func NewTimingWheel(tick time.Duration, slots int) *TimingWheel {
+	this := newTimingWheel(tick, slots)
+	go this.runLoop()
+	return this
+}
+
+//construct without run loop
+func newTimingWheel(tick time.Duration, slots int) *TimingWheel {
+	if tick <= 0 {
+		tick = time.Second
+	}
+	if slots <= 0 {
+		slots = 1
+	}
+	this := &TimingWheel{
+		tick:      tick,
+		slots:     make([]map[int64]*wheelTask, slots),
+		taskMap:   map[int64]*wheelTask{},
+		closeChan: make(chan bool, 1),
+	}
+	for i := range this.slots {
+		this.slots[i] = map[int64]*wheelTask{}
+	}
+	return this
+}
+
+//quit
+func (f *TimingWheel) Quit() {
+	f.closeOnce.Do(func() {
+		close(f.closeChan)
+	})
+}
+
+//add or reset task
+//cb run in new goroutine when expired
+func (f *TimingWheel) Add(key int64, delay time.Duration, cb func()) error {
+	//check
+	if cb == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//calculate ticks
+	ticks := int(delay / f.tick)
+	if delay%f.tick > 0 {
+		ticks++
+	}
+	if ticks <= 0 {
+		ticks = 1
+	}
+
+	//add with locker
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.remove(key)
+	task := &wheelTask{
+		key:    key,
+		rounds: (ticks - 1) / len(f.slots),
+		slot:   (f.pos + ticks) % len(f.slots),
+		cb:     cb,
+	}
+	f.slots[task.slot][key] = task
+	f.taskMap[key] = task
+	return nil
+}
+
+//remove task
+func (f *TimingWheel) Remove(key int64) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.remove(key)
+}
+
+//get total tasks
+func (f *TimingWheel) GetTotal() int {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	return len(f.taskMap)
+}
+
+//remove task without locker
+func (f *TimingWheel) remove(key int64) {
+	task, ok := f.taskMap[key]
+	if !ok {
+		return
+	}
+	delete(f.slots[task.slot], key)
+	delete(f.taskMap, key)
+}
+
+//move to next slot and collect expired tasks
+func (f *TimingWheel) onTick() []*wheelTask {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	f.pos = (f.pos + 1) % len(f.slots)
+	expired := make([]*wheelTask, 0)
+	for key, task := range f.slots[f.pos] {
+		if task.rounds > 0 {
+			task.rounds--
+			continue
+		}
+		expired = append(expired, task)
+		delete(f.slots[f.pos], key)
+		delete(f.taskMap, key)
+	}
+	return expired
+}
+
+//run expired task
+func (f *TimingWheel) runTask(task *wheelTask) {
+	var (
+		m any = nil
+	)
+	defer func() {
+		if pErr := recover(); pErr != m {
+			log.Printf("timing wheel task %v panic, err:%v\n", task.key, pErr)
+		}
+	}()
+	task.cb()
+}
+
+//main loop
+func (f *TimingWheel) runLoop() {
+	ticker := time.NewTicker(f.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, task := range f.onTick() {
+				go f.runTask(task)
+			}
+		case <-f.closeChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package face
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingWheelAdd(t *testing.T) {
+	cases := []struct {
+		slots  int
+		pos    int
+		delay  time.Duration
+		rounds int
+		slot   int
+		ticks  int //ticks to expire
+	}{
+		{10, 0, 0, 0, 1, 1},
+		{10, 0, time.Second, 0, 1, 1},
+		{10, 0, 1500 * time.Millisecond, 0, 2, 2},
+		{10, 0, 9 * time.Second, 0, 9, 9},
+		{10, 0, 10 * time.Second, 0, 0, 10},
+		{10, 0, 11 * time.Second, 1, 1, 11},
+		{10, 0, 20 * time.Second, 1, 0, 20},
+		{10, 0, 25 * time.Second, 2, 5, 25},
+		{10, 7, 5 * time.Second, 0, 2, 5},
+		{10, 9, 21 * time.Second, 2, 0, 21},
+		{1, 0, 3 * time.Second, 2, 0, 3},
+	}
+	for idx, c := range cases {
+		wheel := newTimingWheel(time.Second, c.slots)
+		wheel.pos = c.pos
+		expired := false
+		if err := wheel.Add(1, c.delay, func() { expired = true }); err != nil {
+			t.Fatalf("case %v add failed, err:%v", idx, err)
+		}
+		task := wheel.taskMap[1]
+		if task.rounds != c.rounds || task.slot != c.slot {
+			t.Errorf("case %v rounds:%v slot:%v, want rounds:%v slot:%v",
+				idx, task.rounds, task.slot, c.rounds, c.slot)
+		}
+
+		//check expire tick
+		ticks := 0
+		for !expired && ticks <= c.ticks {
+			ticks++
+			for _, v := range wheel.onTick() {
+				v.cb()
+			}
+		}
+		if !expired || ticks != c.ticks {
+			t.Errorf("case %v expired:%v after %v ticks, want %v ticks", idx, expired, ticks, c.ticks)
+		}
+		if wheel.GetTotal() != 0 {
+			t.Errorf("case %v total %v after expired, want 0", idx, wheel.GetTotal())
+		}
+	}
+}
+
+func TestTimingWheelResetAndRemove(t *testing.T) {
+	wheel := newTimingWheel(time.Hour, 4)
+	noop := func() {}
+	wheel.Add(1, time.Hour, noop)
+	wheel.Add(2, time.Hour, noop)
+
+	//reset same key moves task
+	wheel.Add(1, 3*time.Hour, noop)
+	if wheel.GetTotal() != 2 {
+		t.Fatalf("total %v, want 2", wheel.GetTotal())
+	}
+	if _, ok := wheel.slots[1][1]; ok {
+		t.Fatal("reset task still in old slot")
+	}
+	if _, ok := wheel.slots[3][1]; !ok {
+		t.Fatal("reset task not in new slot")
+	}
+
+	//remove
+	wheel.Remove(2)
+	wheel.Remove(3)
+	if wheel.GetTotal() != 1 || len(wheel.slots[1]) != 0 {
+		t.Fatalf("total %v after remove, want 1", wheel.GetTotal())
+	}
+	if err := wheel.Add(3, time.Hour, nil); err == nil {
+		t.Fatal("add nil callback should fail")
+	}
+}
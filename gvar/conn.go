@@ -0,0 +1,118 @@
+package gvar
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * connector variables define
+ */
+
+//close initiator
+const (
+	CloseByServer  = iota //closed by server side
+	CloseByClient         //closed by close frame of client
+	CloseByNetwork        //read or write failed, or lost without close frame
+)
+
+type (
+	//connector close reason
+	CloseReason struct {
+		Code      int    //websocket close status code, sent by client or 1006 if connect lost
+		Reason    string //close reason text
+		Initiator int    //who close the connect
+		Err       error  //underlying error
+	}
+
+	//connector statistics snapshot
+	ConnStats struct {
+		ConnId          int64
+		OwnerId         int64
+		ConnectTime     time.Time
+		ReadMessages    int64
+		ReadBytes       int64
+		WriteMessages   int64
+		WriteBytes      int64
+		DroppedMessages int64 //dropped by full queue or rate limit
+		RateLimited     int64 //rate limit triggered times
+		TooBigMessages  int64 //rejected by max message size or frames
+		WriteQueueDepth int   //messages waiting in write queue
+		ReadQueueDepth  int   //messages waiting for read cb
+		LastReadTime    time.Time
+		LastWriteTime   time.Time
+		LastPingRtt     time.Duration //measured by ping of `PingInterval`, or fed by `UpdatePingRtt`
+		CloseReason     *CloseReason //nil means still opening
+	}
+
+	//kept message of parked connect, replayed when session resumed
+	MissedMsg struct {
+		Data        interface{}
+		MessageType int
+		InQueue     bool //written by QueueWrite
+		DirectWrite bool
+	}
+)
+
+//format close reason
+func (r *CloseReason) String() string {
+	if r == nil {
+		return ""
+	}
+	if r.Err != nil {
+		return fmt.Sprintf("code:%v, reason:%v, initiator:%v, err:%v", r.Code, r.Reason, r.Initiator, r.Err)
+	}
+	return fmt.Sprintf("code:%v, reason:%v, initiator:%v", r.Code, r.Reason, r.Initiator)
+}
+
+//rate limit kind
+const (
+	RateLimitOfConn  = iota //per connection
+	RateLimitOfOwner        //per owner id
+	RateLimitOfIp           //per remote ip
+)
+
+//rate limit policy
+const (
+	RateLimitPolicyOfDrop       = iota //drop the message
+	RateLimitPolicyOfDelay             //pause reading until tokens available
+	RateLimitPolicyOfDisconnect        //close the connect
+)
+
+type (
+	//token bucket rate limit conf
+	RateLimitConf struct {
+		Rate   float64 //messages per second
+		Burst  int     //max messages of burst, zero means rate
+		Policy int
+	}
+)
+
+type (
+	//server wide connect admission conf
+	AdmissionConf struct {
+		MaxConns       int    //max connects of server, zero means no limit
+		MaxConnsPerIp  int    //max connects of one remote ip
+		MaxGoroutines  int    //reject when goroutines over it
+		MaxMemoryBytes uint64 //reject when heap memory over it
+
+		//pluggable admission policy, return error to reject
+		CBForAdmit func(req *http.Request) error
+	}
+)
+
+//idle mode
+const (
+	IdleModeOfRead      = iota //no inbound traffic
+	IdleModeOfReadWrite        //no traffic in either direction
+)
+
+//owner policy, when one owner has multi connects
+const (
+	OwnerPolicyOfMulti     = iota //allow many connects
+	OwnerPolicyOfKickOld          //keep newest and kick older connects
+	OwnerPolicyOfRejectNew        //reject new connect
+)
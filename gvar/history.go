@@ -0,0 +1,39 @@
+package gvar
+
+import "encoding/json"
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * group history variables define
+ */
+
+type (
+	//history message of group
+	HistoryMsg struct {
+		Group string          `json:"group"`
+		Seq   int64           `json:"seq"`  //increasing seq of group
+		Time  int64           `json:"time"` //unix milli seconds
+		Data  json.RawMessage `json:"data"` //json encoded cast data, octet data as base64 string
+	}
+
+	//group cast envelope with seq
+	CastMsg struct {
+		Ctrl string `json:"ctrl"` //always `cast`
+		HistoryMsg
+	}
+
+	//history query, all conditions are optional
+	HistoryQuery struct {
+		Last      int   `json:"last,omitempty"`       //last N messages
+		SinceSeq  int64 `json:"since_seq,omitempty"`  //messages after this seq
+		SinceTime int64 `json:"since_time,omitempty"` //messages from this unix milli seconds
+	}
+
+	//history replay message
+	HistoryReply struct {
+		Ctrl  string        `json:"ctrl"`
+		Group string        `json:"group"`
+		Msgs  []*HistoryMsg `json:"msgs"`
+	}
+)
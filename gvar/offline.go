@@ -0,0 +1,18 @@
+package gvar
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * offline message variables define
+ */
+
+type (
+	//kept message of offline owner
+	OfflineMsg struct {
+		Data        []byte `json:"data"` //json mode data kept as encoded json
+		MessageType int    `json:"type"`
+		InQueue     bool   `json:"queue,omitempty"` //written by QueueWrite
+		DirectWrite bool   `json:"direct,omitempty"`
+		Time        int64  `json:"time"` //unix milli seconds
+	}
+)
@@ -0,0 +1,28 @@
+package gvar
+
+import (
+	"encoding/json"
+	"time"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * reliable delivery variables define
+ */
+
+type (
+	//reliable delivery conf
+	ReliableConf struct {
+		MaxMessages int           //max retained messages of one owner, oldest dropped when full
+		RetainTime  time.Duration //max retain time of un-acked message
+	}
+
+	//reliable message envelope
+	//server send `msg` with seq, client reply `ack` with same seq
+	ReliableMsg struct {
+		Rel  string          `json:"rel"`
+		Seq  int64           `json:"seq"`
+		Data json.RawMessage `json:"data,omitempty"`
+	}
+)
@@ -1,8 +1,10 @@
 package gvar
 
 import (
-	"golang.org/x/net/websocket"
+	"encoding/json"
 	"time"
+
+	"golang.org/x/net/websocket"
 )
 
 /*
@@ -28,27 +30,203 @@ type (
 		WriteTimeout time.Duration
 		MessageType  int
 
+		//max bytes of one received message, zero means 32MB
+		//fragmented message delivered frame by frame,
+		//it limits each frame and total bytes of all frames
+		MaxMessageSize int
+
+		//max frames of one received fragmented message, zero means no limit
+		//connect closed with 1009 when message over limits
+		MaxFramesPerMessage int
+
+		//inbound rate limit, nil means no limit
+		ConnRateLimit  *RateLimitConf
+		OwnerRateLimit *RateLimitConf
+		IpRateLimit    *RateLimitConf
+
+		//connect admission limit, zero means no limit
+		MaxConns          int
+		MaxConnsPerIp     int
+		MaxConnsPerBucket int
+
+		//idle connect eviction, zero means never
+		IdleTimeout time.Duration
+		IdleMode    int
+
+		//send ping frame on this interval and measure rtt by pong, zero means disabled
+		PingInterval time.Duration
+
+		//policy for one owner with multi connects
+		OwnerPolicy int
+
+		//request and response rpc over connect
+		EnableRpc bool
+
+		//json rpc 2.0 mode, all requests dispatched to registered handlers
+		//use octet message type to keep big number id and reply parse error
+		JsonRpc bool
+
+		//named event mode, event without handler passed to read cb
+		EnableEvent bool
+
+		//handle pub/sub control message like `{"ctrl":"sub","topic":"orders.>"}`
+		EnablePubSub bool
+
+		//reliable delivery, connector bound to owner receive retained messages
+		//and ack message like `{"rel":"ack","seq":1}`
+		EnableReliable bool
+
+		//keep session of client lost by network for this grace window, zero means disabled
+		//client reconnect with token query para `session` to resume it,
+		//closed cb delayed until grace window expired
+		ResumeGrace time.Duration
+
+		//keep owner targeted cast in memory when owner has no connect, zero size means disabled
+		//use router `SetOfflineStore` for file backed or custom store,
+		//kept messages delivered in order when owner bound again
+		OfflineSize int
+		OfflineTTL  time.Duration //zero means never expired
+
+		//all writes of connect pass write queue in call order,
+		//write still return until sent
+		OrderedWrite bool
+
 		//cb func for websocket
 		CBForGenConnId func() int64
 		CBForConnected func(router interface{}, bucketId int, connector interface{}) error
-		CBForClosed    func(router interface{}, bucketId int, connId int64) error
+		CBForClosed    func(router interface{}, bucketId int, connId int64, reason *CloseReason) error
 		CBForRead      func(router interface{}, bucketId int, connId int64, messageType int, data interface{}) error
+		CBForTooBig    func(router interface{}, bucketId int, connId int64) error //message over max size
+		CBForLimited   func(router interface{}, bucketId int, connId int64, kind int) error //rate limit triggered
+		CBForResumed   func(router interface{}, bucketId int, connector interface{}) error         //session resumed
 	}
 
 	//dynamic group conf
 	GroupConf struct {
 		//general
-		Uri          string //final format like `/<orgUri>/{groupId}`
+		Uri          string //template like `/<orgUri>/{org}/{group}`, `/{group}` appended if not assigned
 		ReadTimeout  time.Duration
 		WriteTimeout time.Duration
 		MessageType  int
 
+		//max bytes of one received message, zero means 32MB
+		//fragmented message delivered frame by frame,
+		//it limits each frame and total bytes of all frames
+		MaxMessageSize int
+
+		//max frames of one received fragmented message, zero means no limit
+		//connect closed with 1009 when message over limits
+		MaxFramesPerMessage int
+
+		//inbound rate limit, nil means no limit
+		ConnRateLimit  *RateLimitConf
+		OwnerRateLimit *RateLimitConf
+		IpRateLimit    *RateLimitConf
+
+		//connect admission limit, zero means no limit
+		MaxConns         int
+		MaxConnsPerIp    int
+		MaxConnsPerGroup int
+
+		//idle connect eviction, zero means never
+		IdleTimeout time.Duration
+		IdleMode    int
+
+		//send ping frame on this interval and measure rtt by pong, zero means disabled
+		PingInterval time.Duration
+
+		//policy for one owner with multi connects, checked in group,
+		//or all groups of dynamic in multi groups mode
+		OwnerPolicy int
+
+		//request and response rpc over connect
+		EnableRpc bool
+
+		//json rpc 2.0 mode, all requests dispatched to registered handlers
+		//use octet message type to keep big number id and reply parse error
+		JsonRpc bool
+
+		//named event mode, event without handler passed to read cb
+		EnableEvent bool
+
+		//handle pub/sub control message like `{"ctrl":"sub","topic":"orders.>"}`
+		EnablePubSub bool
+
+		//reliable delivery, connector bound to owner receive retained messages
+		//and ack message like `{"rel":"ack","seq":1}`
+		EnableReliable bool
+
+		//multi groups mode, one connect join and leave many groups
+		//by api or control message like `{"ctrl":"join","group":1}`,
+		//cb for connected, closed and read run with dynamic obj and empty group id
+		MultiGroup bool
+
+		//auto create group on first join, guarded by CBForVerifyGroup
+		AutoCreateGroup bool
+
+		//reclaim group after empty for this grace period, zero means never
+		EmptyGroupTTL time.Duration
+
+		//keep session of client lost by network for this grace window, zero means disabled
+		//client reconnect with token query para `session` to resume it,
+		//closed cb delayed until grace window expired
+		ResumeGrace time.Duration
+
+		//in memory group history recorded from untargeted cast, zero size means disabled
+		//use dynamic `SetHistoryStore` for file backed or custom store,
+		//history removed with group, replay last N messages on join if HistoryReplay assigned
+		HistorySize   int
+		HistoryAge    time.Duration //zero means no age limit
+		HistoryReplay int
+
+		//keep owner targeted cast in memory when owner has no connect, zero size means disabled
+		//use dynamic `SetOfflineStore` for file backed or custom store,
+		//kept messages delivered in order when owner bound again
+		OfflineSize int
+		OfflineTTL  time.Duration //zero means never expired
+
+		//all writes of connect pass write queue in call order,
+		//write still return until sent
+		OrderedWrite bool
+
+		//wrap untargeted and unfiltered cast like `{"ctrl":"cast","group":"1","seq":5,"time":1,"data":{}}`,
+		//seq increase per group, shared with history if enabled,
+		//client request replay of gaps by control message like `{"ctrl":"history","group":"1","history":{"since_seq":4}}`
+		CastSeq bool
+
 		//cb func for websocket
 		CBForGenConnId   func() int64
-		CBForVerifyGroup func(conn *websocket.Conn, groupObj interface{}, groupId int64) error
-		CBForConnected   func(groupObj interface{}, groupId int64, connector interface{}) error
-		CBForClosed      func(groupObj interface{}, groupId int64, connId int64) error
-		CBForRead        func(groupObj interface{}, groupId int64, connId int64, messageType int, data interface{}) error
+		CBForVerifyGroup func(conn *websocket.Conn, groupObj interface{}, groupId string) error
+		CBForConnected   func(groupObj interface{}, groupId string, connector interface{}) error
+		CBForClosed      func(groupObj interface{}, groupId string, connId int64, reason *CloseReason) error
+		CBForRead        func(groupObj interface{}, groupId string, connId int64, messageType int, data interface{}) error
+		CBForTooBig      func(groupObj interface{}, groupId string, connId int64) error //message over max size
+		CBForLimited     func(groupObj interface{}, groupId string, connId int64, kind int) error //rate limit triggered
+		CBForJoin        func(groupObj interface{}, groupId string, connector interface{}) error //multi groups mode
+		CBForLeave       func(groupObj interface{}, groupId string, connId int64) error          //multi groups mode
+		CBForResumed     func(groupObj interface{}, groupId string, connector interface{}) error //session resumed
+
+		//cb func for group
+		CBForGroupCreated func(groupObj interface{}, groupId string) error
+		CBForGroupRemoved func(groupObj interface{}, groupId string) error
+	}
+
+	//control message of multi groups, pub/sub and session
+	ControlMsg struct {
+		Ctrl   string `json:"ctrl"`
+		Group  string `json:"group,omitempty"`
+		Topic  string `json:"topic,omitempty"` //pub/sub topic pattern
+		Token  string `json:"token,omitempty"` //session resume token
+
+		//history replay of join, nil means default replay
+		History *HistoryQuery `json:"history,omitempty"`
+		Reason string `json:"reason,omitempty"`
+	}
+
+	//published message of topic
+	PubMsg struct {
+		Topic string          `json:"topic"`
+		Data  json.RawMessage `json:"data,omitempty"`
 	}
 
 	MsgData struct {
@@ -57,5 +235,28 @@ type (
 		OwnerIds     []int64
 		ConnIds      []int64
 		WriteInQueue bool //if true, data should be []byte type
+
+		//filter of target connects
+		ExcludeConnIds  []int64
+		ExcludeOwnerIds []int64
+		PropMatches     []string                         //connect prop match like `role=admin` or `role!=guest`
+		Filter          func(connector interface{}) bool //return true if connector should receive
+
+		//receive delivery report of this cast, should be buffered
+		ReportChan chan *DeliveryReport
+
+		//not recorded into group history
+		SkipHistory bool
+	}
+
+	//delivery report of one cast
+	//queue write counted as delivered once queued
+	DeliveryReport struct {
+		Targets         int //matched target connects
+		Delivered       int
+		Failed          int
+		MissingConnIds  []int64         //assigned connect ids not found
+		MissingOwnerIds []int64         //assigned owner ids without connect
+		Errors          map[int64]error //connId -> write error
 	}
 )
\ No newline at end of file
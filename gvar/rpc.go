@@ -0,0 +1,84 @@
+package gvar
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * rpc variables define
+ */
+
+//rpc error code
+const (
+	RpcCodeOfUnknownMethod = 404 //no handler of method
+	RpcCodeOfInternal      = 500 //handler failed
+	RpcCodeOfClosed        = 503 //connect closed before response
+)
+
+type (
+	//rpc handler
+	//connector is IConnector of server side, or *Client of client side
+	//return value will be json encoded, return *RpcError for typed error
+	RpcHandler func(connector interface{}, data json.RawMessage) (interface{}, error)
+
+	//rpc message envelope
+	RpcMsg struct {
+		Rpc    string          `json:"rpc"` //request or response
+		Id     int64           `json:"id"`  //correlation id
+		Method string          `json:"method,omitempty"`
+		Data   json.RawMessage `json:"data,omitempty"`
+		Error  *RpcError       `json:"error,omitempty"`
+	}
+
+	//rpc typed error
+	RpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+//format rpc error
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("rpc error, code:%v, message:%v", e.Code, e.Message)
+}
+
+//json rpc 2.0 error code
+const (
+	JsonRpcCodeOfParseError     = -32700
+	JsonRpcCodeOfInvalidRequest = -32600
+	JsonRpcCodeOfMethodNotFound = -32601
+	JsonRpcCodeOfInvalidParams  = -32602
+	JsonRpcCodeOfInternalError  = -32603
+)
+
+type (
+	//json rpc 2.0 error object
+	JsonRpcError struct {
+		Code    int         `json:"code"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
+	}
+)
+
+//format json rpc error
+func (e *JsonRpcError) Error() string {
+	return fmt.Sprintf("json rpc error, code:%v, message:%v", e.Code, e.Message)
+}
+
+type (
+	//event handler
+	//connector is IConnector of server side, or *Client of client side
+	//call ack to reply sender, no-op if sender not waiting ack
+	EventHandler func(connector interface{}, data json.RawMessage, ack func(data interface{}) error)
+
+	//event message envelope
+	//ack reply has ack id without event name
+	EventMsg struct {
+		Event string          `json:"event,omitempty"`
+		Data  json.RawMessage `json:"data,omitempty"`
+		Ack   int64           `json:"ack,omitempty"` //ack id, zero means no ack
+	}
+)
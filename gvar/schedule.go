@@ -0,0 +1,19 @@
+package gvar
+
+import "time"
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * scheduled cast variables define
+ */
+
+type (
+	//scheduled message waiting for delivery
+	ScheduledMsg struct {
+		Id     int64     //cancel handle
+		Target string    //group id for group, owner id for owner, empty for router
+		SendAt time.Time //planned delivery time
+		Msg    *MsgData
+	}
+)
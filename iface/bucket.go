@@ -15,13 +15,16 @@ import (
 type IBucket interface {
 	//gen opt
 	Quit()
+	GetTotal() int
 	Broadcast(data *gvar.MsgData) error
+	BroadcastWithReport(data *gvar.MsgData) (*gvar.DeliveryReport, error)
 	SetOwner(connId, ownerId int64) error
 
 	//for connect
 	CloseConn(connId int64) error
 	RemoveConn(connId int64) (IConnector, error)
 	GetConnByOwnerId(ownerId int64) (IConnector, error)
+	GetConnsByOwnerId(ownerId int64) ([]IConnector, error)
 	GetConn(connId int64) (IConnector, error)
 	AttachConn(connector IConnector) error
 	AddConn(connId int64, conn *websocket.Conn, timeouts ...time.Duration) error
@@ -1,8 +1,13 @@
 package iface
 
 import (
-	"golang.org/x/net/websocket"
+	"context"
+	"encoding/json"
 	"net/url"
+	"time"
+
+	"github.com/andyzhou/websocket/gvar"
+	"golang.org/x/net/websocket"
 )
 
 /*
@@ -13,11 +18,16 @@ import (
 type IConnector interface {
 	//gen opt
 	Close()
-	CloseWithMessage(message string) error
+	Done() <-chan bool
+	CloseWithCode(code int, reason string) error
+	GetCloseReason() *gvar.CloseReason
 	GetUriParas() map[string]string
 	GetUriQueryParas() url.Values
 	GetActiveTime() int64
-	SetConfId(bucketId int, groupId int64)
+	GetRemoteIp() string
+	Stats() gvar.ConnStats
+	UpdatePingRtt(rtt time.Duration)
+	SetConfId(bucketId int, groupId string)
 
 	//owner id
 	GetOwnerId() int64
@@ -27,12 +37,24 @@ type IConnector interface {
 	RemoveProp(kind string) error
 	GetProp(kind string) (interface{}, error)
 	SetProp(kind string, val interface{}) error
+	GetProps() map[string]interface{}
 
 	//read and write
+	GetMessageType() int
 	QueueWrite(data []byte, directWrites ...bool) error
 	Write(data interface{}, messageTypes ...int) error
 	Read(messageTypes ...int) (interface{}, error)
 
+	//rpc call to client
+	Call(ctx context.Context, method string, payload interface{}) (json.RawMessage, error)
+
+	//named event to client
+	Emit(event string, data interface{}, acks ...func(data json.RawMessage)) error
+
+	//session resumption
+	Park(maxMessages int)
+	TakeMissed() []*gvar.MissedMsg
+
 	//connect
 	GetConnId() int64
 	GetConn() *websocket.Conn
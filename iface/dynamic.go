@@ -1,6 +1,8 @@
 package iface
 
 import (
+	"net/http"
+
 	"github.com/andyzhou/websocket/gvar"
 	"golang.org/x/net/websocket"
 )
@@ -14,9 +16,31 @@ import (
 type IDynamic interface {
 	Quit()
 	GetConf() *gvar.GroupConf
-	RemoveGroup(groupId int64) error
-	GetGroup(groupId int64) (IGroup, error)
-	CreateGroup(groupId int64) (IGroup, error)
-	Cast(groupId int64, msg *gvar.MsgData) error
+	RemoveGroup(groupId string) error
+	GetGroup(groupId string) (IGroup, error)
+	CreateGroup(groupId string) (IGroup, error)
+	Cast(groupId string, msg *gvar.MsgData) error
+	CastWithReport(groupId string, msg *gvar.MsgData) (*gvar.DeliveryReport, error)
+	RegisterRpc(method string, handler gvar.RpcHandler) error
+	RegisterJsonRpc(method string, handler interface{}) error
+	Notify(groupId string, method string, params interface{}, target *gvar.MsgData) error
+	On(event string, handler gvar.EventHandler) error
+	SetHistoryStore(store IHistoryStore)
+	SetOfflineStore(store IOfflineStore)
+
+	//int64 group id convenience
+	RemoveGroupInt(groupId int64) error
+	GetGroupInt(groupId int64) (IGroup, error)
+	CreateGroupInt(groupId int64) (IGroup, error)
+	CastInt(groupId int64, msg *gvar.MsgData) error
+
+	//for multi groups mode
+	GetConn(connId int64) (IConnector, error)
+	SetOwner(connId, ownerId int64) error
+	JoinGroup(connId int64, groupId string) error
+	LeaveGroup(connId int64, groupId string) error
+	GetGroupsOfConn(connId int64) []string
+
+	Admit(req *http.Request) (func(), error)
 	Entry(conn *websocket.Conn)
 }
\ No newline at end of file
@@ -16,13 +16,26 @@ type IGroup interface {
 	//gen opt
 	Quit()
 	Cast(data *gvar.MsgData) error
+	CastWithReport(data *gvar.MsgData) (*gvar.DeliveryReport, error)
+	CastAt(at time.Time, data *gvar.MsgData) (int64, error)
+	CastAfter(delay time.Duration, data *gvar.MsgData) (int64, error)
+	CancelCast(id int64) error
+	GetScheduledCasts() []*gvar.ScheduledMsg
+	Emit(event string, data interface{}) error
 	GetTotal() int
-	GetId() int64
+	GetId() string
+	GetIntId() (int64, error)
+	GetHistory(query *gvar.HistoryQuery) ([]*gvar.HistoryMsg, error)
 
 	//for connect
 	SetOwner(connId, ownerId int64) error
 	CloseConn(connId int64) error
+	Join(connector IConnector) error
+	Leave(connId int64) error
+	ReplaceConn(connector IConnector) error
+	Replay(connId int64, query *gvar.HistoryQuery) error
 	GetConnByOwnerId(ownerId int64) (IConnector, error)
+	GetConnsByOwnerId(ownerId int64) ([]IConnector, error)
 	GetConn(connId int64) (IConnector, error)
 	AddConn(connId int64, conn *websocket.Conn, timeouts ...time.Duration) error
 }
@@ -0,0 +1,20 @@
+package iface
+
+import (
+	"encoding/json"
+
+	"github.com/andyzhou/websocket/gvar"
+)
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * interface of group history store
+ */
+
+type IHistoryStore interface {
+	Quit()
+	Append(groupId string, data json.RawMessage) (*gvar.HistoryMsg, error) //seq assigned by store
+	Query(groupId string, query *gvar.HistoryQuery) ([]*gvar.HistoryMsg, error)
+	Remove(groupId string) error
+}
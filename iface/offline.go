@@ -0,0 +1,17 @@
+package iface
+
+import "github.com/andyzhou/websocket/gvar"
+
+/*
+ * @author <AndyZhou>
+ * @mail <diudiu8848@163.com>
+ * interface of offline message store
+ */
+
+type IOfflineStore interface {
+	Quit()
+	Push(ownerId int64, msg *gvar.OfflineMsg) error
+	PushFront(ownerId int64, msgs []*gvar.OfflineMsg) error //push back undelivered messages before kept
+	Take(ownerId int64) ([]*gvar.OfflineMsg, error) //take all messages in order
+	GetTotal(ownerId int64) int
+}
@@ -1,6 +1,9 @@
 package iface
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/andyzhou/websocket/gvar"
 	"golang.org/x/net/websocket"
 )
@@ -15,8 +18,21 @@ type IRouter interface {
 	Quit()
 	GetConf() *gvar.RouterConf
 	GetConnector(connId int64, bucketIdxes ...int) (IConnector, error)
+	GetConnectorsByOwner(ownerId int64) []IConnector
 	SwitchBucket(connectId int64, from, to int) error
 	Cast(msg *gvar.MsgData) error
+	CastWithReport(msg *gvar.MsgData) (*gvar.DeliveryReport, error)
+	CastAt(at time.Time, msg *gvar.MsgData) (int64, error)
+	CastAfter(delay time.Duration, msg *gvar.MsgData) (int64, error)
+	CancelCast(id int64) error
+	GetScheduledCasts() []*gvar.ScheduledMsg
+	RegisterRpc(method string, handler gvar.RpcHandler) error
+	RegisterJsonRpc(method string, handler interface{}) error
+	Notify(method string, params interface{}, target *gvar.MsgData) error
+	On(event string, handler gvar.EventHandler) error
+	Emit(event string, data interface{}, target *gvar.MsgData) error
 	SetOwner(connId, ownerId int64, bucketIdxes ...int) error
+	SetOfflineStore(store IOfflineStore)
+	Admit(req *http.Request) (func(), error)
 	Entry(conn *websocket.Conn)
 }
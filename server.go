@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/andyzhou/websocket/define"
 	"github.com/andyzhou/websocket/face"
@@ -28,6 +31,11 @@ type Server struct {
 	router     *mux.Router
 	routerMap  map[string]iface.IRouter  //persistent routers, uri -> IRouter
 	dynamicMap map[string]iface.IDynamic //dynamic groups, uri -> IDynamic
+	admission  *face.Admission           //server wide connect admission
+	registry   *face.OwnerRegistry       //server wide owner registry
+	pubSub     *face.PubSub              //server wide topic pub/sub
+	reliable   *face.Reliable            //server wide reliable delivery
+	scheduler  *face.Scheduler           //scheduled send to owner
 	//wg            sync.WaitGroup
 	locker 	   sync.RWMutex
 }
@@ -39,6 +47,10 @@ func NewServer() *Server {
 		router: mux.NewRouter(),
 		routerMap: map[string]iface.IRouter{},
 		dynamicMap: map[string]iface.IDynamic{},
+		registry: face.NewOwnerRegistry(),
+		pubSub: face.NewPubSub(),
+		reliable: face.NewReliable(),
+		scheduler: face.NewScheduler(),
 	}
 	return this
 }
@@ -59,6 +71,10 @@ func (f *Server) Quit() {
 		delete(f.dynamicMap, k)
 	}
 
+	//stop reliable delivery and scheduled send
+	f.reliable.Quit()
+	f.scheduler.Quit()
+
 	//gc opt
 	runtime.GC()
 }
@@ -79,6 +95,138 @@ func (f *Server) Start(port int) error {
 	return nil
 }
 
+//set server wide connect admission
+//should be called before start
+func (f *Server) SetAdmission(conf *gvar.AdmissionConf) {
+	f.admission = face.NewAdmissionByConf(conf)
+}
+
+//set reliable delivery conf
+func (f *Server) SetReliable(conf *gvar.ReliableConf) {
+	f.reliable.SetConf(conf)
+}
+
+//send data to owner with reliable delivery
+//message retained until client ack, and retransmitted when owner reconnect
+//only delivered to connectors of routers or dynamics with `EnableReliable`
+//data will be json encoded, return seq of message
+func (f *Server) SendReliable(ownerId int64, data interface{}) (int64, error) {
+	return f.reliable.Send(ownerId, data)
+}
+
+//get total un-acked reliable messages of owner
+func (f *Server) GetReliablePending(ownerId int64) int {
+	return f.reliable.GetPending(ownerId)
+}
+
+//get all connectors of owner cross routers and dynamic groups
+func (f *Server) GetConnectorsByOwner(ownerId int64) []iface.IConnector {
+	if ownerId <= 0 {
+		return nil
+	}
+	return f.registry.Get(ownerId)
+}
+
+//send message to all connectors of owner
+//if msg.WriteInQueue is true, data should be []byte type
+//written with message type of router or group config
+func (f *Server) SendToOwner(ownerId int64, msg *gvar.MsgData) error {
+	//check
+	if ownerId <= 0 || msg == nil || msg.Data == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//get connectors of owner
+	connectors := f.registry.Get(ownerId)
+	if len(connectors) <= 0 {
+		return fmt.Errorf("owner %v is offline", ownerId)
+	}
+
+	//write to all connectors
+	byteData, _ := msg.Data.([]byte)
+	for _, v := range connectors {
+		if msg.WriteInQueue {
+			v.QueueWrite(byteData)
+		}else{
+			v.Write(msg.Data, v.GetMessageType())
+		}
+	}
+	return nil
+}
+
+//send message to owner at assigned time, return id as cancel handle
+//owner checked when sending, not when scheduled
+func (f *Server) SendToOwnerAt(at time.Time, ownerId int64, msg *gvar.MsgData) (int64, error) {
+	//check
+	if ownerId <= 0 {
+		return 0, errors.New("invalid parameter")
+	}
+	return f.scheduler.Add(at, strconv.FormatInt(ownerId, 10), msg, func(msg *gvar.MsgData) error {
+		return f.SendToOwner(ownerId, msg)
+	})
+}
+
+//send message to owner after delay, return id as cancel handle
+func (f *Server) SendToOwnerAfter(delay time.Duration, ownerId int64, msg *gvar.MsgData) (int64, error) {
+	return f.SendToOwnerAt(time.Now().Add(delay), ownerId, msg)
+}
+
+//cancel scheduled send of owner
+func (f *Server) CancelSendToOwner(ownerId, id int64) error {
+	return f.scheduler.Cancel(id, strconv.FormatInt(ownerId, 10))
+}
+
+//get scheduled sends of owner, ordered by send time
+func (f *Server) GetScheduledSends(ownerId int64) []*gvar.ScheduledMsg {
+	return f.scheduler.GetScheduled(strconv.FormatInt(ownerId, 10))
+}
+
+//send json rpc 2.0 notification to all connectors of owner
+func (f *Server) NotifyOwner(ownerId int64, method string, params interface{}) error {
+	//check
+	if ownerId <= 0 || method == "" {
+		return errors.New("invalid parameter")
+	}
+
+	//get connectors of owner
+	connectors := f.registry.Get(ownerId)
+	if len(connectors) <= 0 {
+		return fmt.Errorf("owner %v is offline", ownerId)
+	}
+
+	//gen notification and write as json text
+	data, err := face.GenJsonRpcNotify(gvar.MessageTypeOfJson, method, params)
+	if err != nil {
+		return err
+	}
+	for _, v := range connectors {
+		v.Write(data, gvar.MessageTypeOfJson)
+	}
+	return nil
+}
+
+//subscribe topic pattern for connector
+//pattern tokens split by `.`, `*` match one token, `>` match one or more tail tokens
+func (f *Server) Subscribe(connector iface.IConnector, pattern string) error {
+	return f.pubSub.Subscribe(connector, pattern)
+}
+
+//unsubscribe topic pattern for connector
+func (f *Server) Unsubscribe(connector iface.IConnector, pattern string) error {
+	return f.pubSub.Unsubscribe(connector, pattern)
+}
+
+//get subscribed topic patterns of connector
+func (f *Server) GetTopics(connector iface.IConnector) []string {
+	return f.pubSub.GetTopics(connector)
+}
+
+//publish data to all connectors subscribed matched topic
+//data will be json encoded, return delivered connectors count
+func (f *Server) Publish(topic string, data interface{}) (int, error) {
+	return f.pubSub.Publish(topic, data)
+}
+
 //get all routers
 func (f *Server) GetAllRouters() map[string]iface.IRouter {
 	f.locker.RLock()
@@ -141,14 +289,26 @@ func (f *Server) RegisterDynamic(cfg *gvar.GroupConf) (iface.IDynamic, error) {
 	}
 
 	//init new sub dynamic face
-	subDynamic := face.NewDynamic(cfg)
+	subDynamic := face.NewDynamic(cfg, f.registry, f.pubSub, f.reliable)
 
 	//format dynamic uri with path para info
-	//path para value used as group id
-	uriWithPathPara := fmt.Sprintf("%v/{%v}", cfg.Uri, define.PathParaNameOfGroup)
+	//path para value used as group id, other path paras kept in uri
+	groupPara := fmt.Sprintf("/{%v}", define.PathParaNameOfGroup)
+	uriWithPathPara := cfg.Uri
+	if !strings.Contains(cfg.Uri, groupPara) {
+		uriWithPathPara = cfg.Uri + groupPara
+	}
 
 	//add websocket sub router handle
-	f.router.Handle(uriWithPathPara, websocket.Handler(subDynamic.Entry))
+	f.router.Handle(uriWithPathPara, f.genHandler(subDynamic.Admit, subDynamic.Entry))
+	if cfg.MultiGroup {
+		//multi groups mode, path group id is optional
+		uriWithoutGroup := strings.Replace(uriWithPathPara, groupPara, "", 1)
+		if uriWithoutGroup == "" {
+			uriWithoutGroup = "/"
+		}
+		f.router.Handle(uriWithoutGroup, f.genHandler(subDynamic.Admit, subDynamic.Entry))
+	}
 
 	//sync into running map with locker
 	f.locker.Lock()
@@ -171,10 +331,10 @@ func (f *Server) RegisterRouter(cfg *gvar.RouterConf) error {
 	}
 
 	//init new sub router face
-	subRouter := face.NewRouter(cfg)
+	subRouter := face.NewRouter(cfg, f.registry, f.pubSub, f.reliable)
 
 	//add websocket sub router handle
-	f.router.Handle(cfg.Uri, websocket.Handler(subRouter.Entry))
+	f.router.Handle(cfg.Uri, f.genHandler(subRouter.Admit, subRouter.Entry))
 
 	//sync into running map with locker
 	f.locker.Lock()
@@ -183,6 +343,38 @@ func (f *Server) RegisterRouter(cfg *gvar.RouterConf) error {
 	return nil
 }
 
+//gen http handler with connect admission
+//reply http 503 if rejected before handshake
+func (f *Server) genHandler(
+	admit func(req *http.Request) (func(), error),
+	entry func(conn *websocket.Conn)) http.Handler {
+	wsHandler := websocket.Handler(entry)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		//check server wide admission
+		if f.admission != nil {
+			release, err := f.admission.Acquire(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			defer release()
+		}
+
+		//check router or dynamic admission
+		release, err := admit(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
+		//serve websocket until connect closed
+		//tap connect for close code of client
+		w, req = face.WrapCloseTap(w, req)
+		wsHandler.ServeHTTP(w, req)
+	})
+}
+
 //generate message data
 func (f *Server) GenMsgData() *gvar.MsgData {
 	return &gvar.MsgData{}